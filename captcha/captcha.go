@@ -0,0 +1,119 @@
+package captcha
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"telegram-bot/render"
+)
+
+// Виды проверок
+const (
+	ModeOff   = ""
+	ModeMath  = "math"
+	ModeEmoji = "emoji"
+	ModeImage = "image"
+)
+
+// Modes - допустимые значения CAPTCHA_MODE
+var Modes = []string{ModeOff, ModeMath, ModeEmoji, ModeImage}
+
+// Каждое задание принимает один ответ, поэтому вариантов много: бот, нажимающий
+// случайные кнопки, угадывает с вероятностью 1/9 за задание
+const (
+	optionsCount = 9
+	// OptionsPerRow - сколько кнопок с вариантами помещается в ряд
+	OptionsPerRow = 3
+)
+
+// Challenge - задание, на которое пользователь отвечает нажатием одной из кнопок
+type Challenge struct {
+	PromptKey string   // ключ локализации с текстом задания
+	PromptArg string   // подставляется в текст задания
+	Image     []byte   // PNG для ModeImage
+	Options   []string // варианты ответа в порядке кнопок
+	Answer    string   // правильный вариант
+}
+
+var emojis = []string{
+	"🍎", "🚗", "🐶", "⚽", "🎸", "🌵", "🚀", "🍕", "🐟", "🌙", "🔑", "🎈",
+	"🐱", "🍌", "🚲", "🌻", "⏰", "🎁", "🦋", "🍩",
+}
+
+// New создает задание указанного вида
+func New(mode string) (*Challenge, error) {
+	switch mode {
+	case ModeMath:
+		return newMath(), nil
+	case ModeEmoji:
+		return newEmoji(), nil
+	case ModeImage:
+		return newImage()
+	default:
+		return nil, fmt.Errorf("unknown captcha mode %q", mode)
+	}
+}
+
+func newMath() *Challenge {
+	a, b := 2+rand.Intn(18), 2+rand.Intn(9)
+	return &Challenge{
+		PromptKey: "captcha_math",
+		PromptArg: fmt.Sprintf("%d + %d", a, b),
+		Options:   numberOptions(a + b),
+		Answer:    strconv.Itoa(a + b),
+	}
+}
+
+func newEmoji() *Challenge {
+	picked := rand.Perm(len(emojis))[:optionsCount]
+	options := make([]string, optionsCount)
+	for i, index := range picked {
+		options[i] = emojis[index]
+	}
+	answer := options[rand.Intn(optionsCount)]
+
+	return &Challenge{
+		PromptKey: "captcha_emoji",
+		PromptArg: answer,
+		Options:   options,
+		Answer:    answer,
+	}
+}
+
+func newImage() (*Challenge, error) {
+	value := 1000 + rand.Intn(9000)
+
+	opts := render.DefaultTextOptions
+	opts.Noise = true
+	image, err := render.PNG(render.Text(strconv.Itoa(value), opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Challenge{
+		PromptKey: "captcha_image",
+		Image:     image,
+		Options:   numberOptions(value),
+		Answer:    strconv.Itoa(value),
+	}, nil
+}
+
+// numberOptions возвращает правильный ответ и близкие к нему неверные в случайном порядке
+func numberOptions(answer int) []string {
+	seen := map[int]bool{answer: true}
+	options := []string{strconv.Itoa(answer)}
+
+	for len(options) < optionsCount {
+		candidate := answer + rand.Intn(31) - 15
+		if candidate <= 0 || seen[candidate] {
+			continue
+		}
+		seen[candidate] = true
+		options = append(options, strconv.Itoa(candidate))
+	}
+
+	rand.Shuffle(len(options), func(i, j int) {
+		options[i], options[j] = options[j], options[i]
+	})
+	return options
+}
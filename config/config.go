@@ -1,77 +1,243 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
-	"strconv"
 	"strings"
+	"telegram-bot/captcha"
+	"telegram-bot/fraud"
+	"telegram-bot/models"
+	"telegram-bot/subscription"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultFile - файл конфигурации, который читается, если путь не задан явно
+const DefaultFile = "config.yaml"
+
 type Config struct {
-	BotToken            string
-	DatabaseFile        string
-	RewardAmount        float64
-	MinWithdrawalAmount float64
-	AdminUserIDs        []int64
+	BotToken            string  `yaml:"bot_token"`
+	DatabaseFile        string  `yaml:"database_file"`
+	RewardAmount        float64 `yaml:"reward_amount"`
+	MinWithdrawalAmount float64 `yaml:"min_withdrawal"`
+	AdminUserIDs        []int64 `yaml:"admin_ids"`
+
+	// Каталог с файлами <lang>.json, дополняющими встроенные переводы (пустой - только встроенные)
+	LocalesDir string `yaml:"locales_dir"`
+	// Не запускать бота, если в переводах есть проблемы (localization.Validate)
+	LocalesStrict bool `yaml:"locales_strict"`
+
+	// Доли награды по уровням реферальной цепочки в процентах: [100, 20, 5]
+	// означает 100% прямому рефереру, 20% его рефереру и 5% следующему
+	ReferralLevels []float64 `yaml:"referral_levels"`
+
+	// Условия подтверждения реферала (models.ReferralCond*), все должны быть выполнены
+	ReferralConditions []string `yaml:"referral_confirm"`
+	ReferralActiveDays int      `yaml:"referral_active_days"`
+
+	// Капча для новых пользователей (captcha.Mode*), пустая строка - выключена
+	CaptchaMode        string        `yaml:"captcha_mode"`
+	CaptchaMaxAttempts int           `yaml:"captcha_max_attempts"`
+	CaptchaLockout     time.Duration `yaml:"captcha_lockout"`
+
+	// Чаты, на которые нужно подписаться, чтобы зарабатывать и выводить средства
+	RequiredChats     []subscription.Chat `yaml:"required_chats"`
+	SubscriptionCache time.Duration       `yaml:"subscription_cache"`
+
+	// Антифрод: рефералы с оценкой не ниже порога задерживаются до проверки, 0 - выключен
+	FraudThreshold  int              `yaml:"fraud_threshold"`
+	FraudThresholds fraud.Thresholds `yaml:"fraud"`
+
+	// Приветственный бонус приглашенному пользователю, 0 - выключен. Бонус
+	// заблокирован до выполнения всех условий (models.BonusUnlock*)
+	WelcomeBonus       float64  `yaml:"welcome_bonus"`
+	WelcomeBonusUnlock []string `yaml:"welcome_bonus_unlock"`
+
+	// Подарки между пользователями
+	GiftMinAmount  float64       `yaml:"gift_min_amount"`
+	GiftDailyLimit float64       `yaml:"gift_daily_limit"` // 0 - без ограничения
+	GiftMinBalance float64       `yaml:"gift_min_balance"` // сколько должно остаться на балансе отправителя
+	VoucherTTL     time.Duration `yaml:"voucher_ttl"`
+}
+
+// Defaults возвращает конфигурацию по умолчанию; без BOT_TOKEN она не проходит Validate
+func Defaults() *Config {
+	return &Config{
+		DatabaseFile:        "bot_users.db",
+		RewardAmount:        0.14,
+		MinWithdrawalAmount: 10.0,
+		ReferralLevels:      []float64{100},
+		ReferralConditions:  []string{models.ReferralCondLanguage},
+		ReferralActiveDays:  3,
+		CaptchaMaxAttempts:  2,
+		CaptchaLockout:      30 * time.Minute,
+		SubscriptionCache:   time.Minute,
+		FraudThreshold:      70,
+		FraudThresholds: fraud.Thresholds{
+			VelocityPerHour: 10,
+			NearbyIDs:       3,
+			FreshChainDepth: 3,
+		},
+		WelcomeBonusUnlock: []string{models.BonusUnlockOnboarding},
+
+		GiftMinAmount:  0.1,
+		GiftDailyLimit: 50.0,
+		VoucherTTL:     72 * time.Hour,
+	}
+}
+
+// ValidationError перечисляет все найденные проблемы конфигурации сразу
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
 }
 
-func Load() *Config {
+// Load собирает конфигурацию: значения по умолчанию, затем YAML-файл path и
+// переменные окружения (в том числе из .env), которые важнее файла. Пустой path -
+// CONFIG_FILE или config.yaml, если он существует
+func Load(path string) (*Config, error) {
 	// Загружаем .env файл, если он существует
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	botToken := os.Getenv("BOT_TOKEN")
-	if botToken == "" {
-		log.Fatal("BOT_TOKEN environment variable is required")
+	cfg := Defaults()
+
+	required := path != ""
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+		required = path != ""
+	}
+	if path == "" {
+		path = DefaultFile
+	}
+	// Ошибки файла, разбора переменных и проверки значений возвращаются вместе
+	problems := cfg.loadFile(path, required)
+
+	env := envReader{}
+	env.apply(cfg)
+	problems = append(problems, env.problems...)
+
+	var validationErr *ValidationError
+	if err := cfg.Validate(); errors.As(err, &validationErr) {
+		problems = append(problems, validationErr.Problems...)
 	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
 
-	databaseFile := os.Getenv("DATABASE_FILE")
-	if databaseFile == "" {
-		databaseFile = "bot_users.db"
+// loadFile накладывает YAML-файл на cfg и возвращает его проблемы. Отсутствующий файл -
+// проблема, только если он задан явно. Неизвестные ключи и неверные значения
+// перечисляются все сразу, остальные ключи файла при этом применяются
+func (c *Config) loadFile(path string, required bool) []string {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
 	}
+	if err != nil {
+		return []string{err.Error()}
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	// Опечатка в имени ключа не должна молча оставлять значение по умолчанию
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
 
-	rewardAmount := 0.14
-	if envReward := os.Getenv("REWARD_AMOUNT"); envReward != "" {
-		if parsed, err := strconv.ParseFloat(envReward, 64); err == nil {
-			rewardAmount = parsed
+	var typeErr *yaml.TypeError
+	switch {
+	case err == nil || errors.Is(err, io.EOF):
+		return nil
+	case errors.As(err, &typeErr):
+		problems := make([]string, 0, len(typeErr.Errors))
+		for _, problem := range typeErr.Errors {
+			problems = append(problems, path+": "+problem)
 		}
+		return problems
+	default:
+		return []string{fmt.Sprintf("%s: %v", path, err)}
 	}
+}
 
-	minWithdrawal := 10.0
-	if envMin := os.Getenv("MIN_WITHDRAWAL"); envMin != "" {
-		if parsed, err := strconv.ParseFloat(envMin, 64); err == nil {
-			minWithdrawal = parsed
+// Validate проверяет значения конфигурации и возвращает *ValidationError со всеми проблемами
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
 		}
 	}
 
-	var adminIDs []int64
-	if envAdmins := os.Getenv("ADMIN_IDS"); envAdmins != "" {
-		for _, idStr := range strings.Split(envAdmins, ",") {
-			if id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64); err == nil {
-				adminIDs = append(adminIDs, id)
-			}
-		}
+	check(c.BotToken != "", "bot_token (BOT_TOKEN) is required")
+	check(c.DatabaseFile != "", "database_file must not be empty")
+	check(c.RewardAmount >= 0, "reward_amount must not be negative, got %g", c.RewardAmount)
+	check(c.MinWithdrawalAmount > 0, "min_withdrawal must be positive, got %g", c.MinWithdrawalAmount)
+	check(c.MinWithdrawalAmount >= c.RewardAmount,
+		"min_withdrawal (%g) must not be below reward_amount (%g)", c.MinWithdrawalAmount, c.RewardAmount)
+
+	check(len(c.ReferralLevels) > 0, "referral_levels must have at least one level")
+	for i, level := range c.ReferralLevels {
+		check(level >= 0, "referral_levels[%d] must not be negative, got %g", i, level)
 	}
+	for _, condition := range c.ReferralConditions {
+		check(contains(models.ReferralConditions, condition), "unknown referral_confirm condition %q", condition)
+	}
+	check(c.ReferralActiveDays >= 0, "referral_active_days must not be negative")
+
+	check(contains(captcha.Modes, c.CaptchaMode), "unknown captcha_mode %q", c.CaptchaMode)
+	check(c.CaptchaMaxAttempts > 0, "captcha_max_attempts must be positive")
+	check(c.CaptchaLockout >= 0, "captcha_lockout must not be negative")
+	check(c.SubscriptionCache >= 0, "subscription_cache must not be negative")
+
+	check(c.FraudThreshold >= 0, "fraud_threshold must not be negative")
+	check(c.FraudThresholds.VelocityPerHour >= 0 && c.FraudThresholds.NearbyIDs >= 0 && c.FraudThresholds.FreshChainDepth >= 0,
+		"fraud thresholds must not be negative")
 
-	if len(adminIDs) == 0 {
-		log.Fatal("ADMIN_IDS environment variable is required (comma-separated list of Telegram user IDs)")
+	check(c.WelcomeBonus >= 0, "welcome_bonus must not be negative, got %g", c.WelcomeBonus)
+	for _, condition := range c.WelcomeBonusUnlock {
+		check(contains(models.BonusUnlockConditions, condition), "unknown welcome_bonus_unlock condition %q", condition)
 	}
 
-	return &Config{
-		BotToken:            botToken,
-		DatabaseFile:        databaseFile,
-		RewardAmount:        rewardAmount,
-		MinWithdrawalAmount: minWithdrawal,
-		AdminUserIDs:        adminIDs,
+	check(c.GiftMinAmount > 0, "gift_min_amount must be positive, got %g", c.GiftMinAmount)
+	check(c.GiftDailyLimit >= 0, "gift_daily_limit must not be negative, got %g", c.GiftDailyLimit)
+	check(c.GiftDailyLimit == 0 || c.GiftDailyLimit >= c.GiftMinAmount,
+		"gift_daily_limit (%g) must not be below gift_min_amount (%g)", c.GiftDailyLimit, c.GiftMinAmount)
+	check(c.GiftMinBalance >= 0, "gift_min_balance must not be negative, got %g", c.GiftMinBalance)
+	check(c.VoucherTTL > 0, "voucher_ttl must be positive")
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ReferralRewards возвращает суммы наград для каждого уровня реферальной цепочки
+func (c *Config) ReferralRewards() []float64 {
+	return c.RewardsFor(c.RewardAmount)
+}
+
+// RewardsFor распределяет по уровням цепочки награду amount вместо RewardAmount
+func (c *Config) RewardsFor(amount float64) []float64 {
+	rewards := make([]float64, len(c.ReferralLevels))
+	for i, percent := range c.ReferralLevels {
+		rewards[i] = amount * percent / 100
 	}
+	return rewards
 }
 
-func (c *Config) IsAdmin(userID int64) bool {
-	for _, adminID := range c.AdminUserIDs {
-		if adminID == userID {
+func contains(values []string, value string) bool {
+	for _, known := range values {
+		if value == known {
 			return true
 		}
 	}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Проблемы файла, переменных окружения и проверки значений сообщаются вместе
+func TestLoadCollectsProblems(t *testing.T) {
+	path := writeConfig(t, `
+bot_token: token
+required_chats: ["@news", "news"]
+reward_amount: 0.5
+min_withdraw: 10
+`)
+	t.Setenv("CAPTCHA_MAX_ATTEMPTS", "many")
+	t.Setenv("MIN_WITHDRAWAL", "0.1")
+
+	_, err := Load(path)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Load error = %v, want *ValidationError", err)
+	}
+	want := []string{
+		`invalid chat "news"`,          // неверный элемент required_chats
+		"field min_withdraw not found", // неизвестный ключ после него
+		"CAPTCHA_MAX_ATTEMPTS",         // переменная окружения
+		"min_withdrawal",               // проверка значений
+	}
+	for _, w := range want {
+		if !containsProblem(validationErr.Problems, w) {
+			t.Errorf("no problem mentioning %q in:\n%s", w, err)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeConfig(t, `
+bot_token: token
+required_chats: ["@news", "-1001234567890|https://t.me/+invite"]
+captcha_lockout: 10m
+`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.RequiredChats) != 2 || cfg.RequiredChats[0].Username != "news" || cfg.RequiredChats[1].ID != -1001234567890 {
+		t.Errorf("RequiredChats = %+v", cfg.RequiredChats)
+	}
+	if cfg.CaptchaLockout.Minutes() != 10 {
+		t.Errorf("CaptchaLockout = %v, want 10m", cfg.CaptchaLockout)
+	}
+	if cfg.VoucherTTL != Defaults().VoucherTTL {
+		t.Errorf("VoucherTTL = %v, want default", cfg.VoucherTTL)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+
+	_, err := Load(path)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || !containsProblem(validationErr.Problems, "missing.yaml") {
+		t.Fatalf("Load error = %v, want a problem about the missing file", err)
+	}
+}
+
+func containsProblem(problems []string, text string) bool {
+	for _, problem := range problems {
+		if strings.Contains(problem, text) {
+			return true
+		}
+	}
+	return false
+}
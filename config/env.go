@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"telegram-bot/subscription"
+	"time"
+)
+
+// envReader накладывает переменные окружения на конфигурацию и запоминает
+// значения, которые не удалось разобрать, вместо того чтобы молча их пропускать
+type envReader struct {
+	problems []string
+}
+
+func (e *envReader) apply(c *Config) {
+	e.string("BOT_TOKEN", &c.BotToken)
+	e.string("DATABASE_FILE", &c.DatabaseFile)
+	e.float("REWARD_AMOUNT", &c.RewardAmount)
+	e.float("MIN_WITHDRAWAL", &c.MinWithdrawalAmount)
+	// ADMIN_IDS используется только для назначения первых владельцев,
+	// дальше администраторы управляются из бота
+	e.ids("ADMIN_IDS", &c.AdminUserIDs)
+
+	e.string("LOCALES_DIR", &c.LocalesDir)
+	e.bool("LOCALES_STRICT", &c.LocalesStrict)
+
+	e.floats("REFERRAL_LEVELS", &c.ReferralLevels)
+	e.list("REFERRAL_CONFIRM", &c.ReferralConditions)
+	e.int("REFERRAL_ACTIVE_DAYS", &c.ReferralActiveDays)
+
+	e.string("CAPTCHA_MODE", &c.CaptchaMode)
+	e.int("CAPTCHA_MAX_ATTEMPTS", &c.CaptchaMaxAttempts)
+	e.duration("CAPTCHA_LOCKOUT_MINUTES", time.Minute, &c.CaptchaLockout)
+
+	e.chats("REQUIRED_CHATS", &c.RequiredChats)
+	e.duration("SUBSCRIPTION_CACHE_SECONDS", time.Second, &c.SubscriptionCache)
+
+	e.int("FRAUD_THRESHOLD", &c.FraudThreshold)
+	e.int("FRAUD_VELOCITY_PER_HOUR", &c.FraudThresholds.VelocityPerHour)
+	e.int("FRAUD_NEARBY_IDS", &c.FraudThresholds.NearbyIDs)
+	e.int("FRAUD_FRESH_CHAIN", &c.FraudThresholds.FreshChainDepth)
+
+	e.float("WELCOME_BONUS", &c.WelcomeBonus)
+	e.list("WELCOME_BONUS_UNLOCK", &c.WelcomeBonusUnlock)
+
+	e.float("GIFT_MIN_AMOUNT", &c.GiftMinAmount)
+	e.float("GIFT_DAILY_LIMIT", &c.GiftDailyLimit)
+	e.float("GIFT_MIN_BALANCE", &c.GiftMinBalance)
+	e.duration("VOUCHER_TTL_HOURS", time.Hour, &c.VoucherTTL)
+}
+
+func (e *envReader) invalid(name, value, expected string) {
+	e.problems = append(e.problems, fmt.Sprintf("%s=%q is not %s", name, value, expected))
+}
+
+func (e *envReader) string(name string, target *string) {
+	if value := strings.TrimSpace(os.Getenv(name)); value != "" {
+		*target = value
+	}
+}
+
+func (e *envReader) float(name string, target *float64) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		e.invalid(name, value, "a number")
+		return
+	}
+	*target = parsed
+}
+
+func (e *envReader) int(name string, target *int) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		e.invalid(name, value, "an integer")
+		return
+	}
+	*target = parsed
+}
+
+func (e *envReader) bool(name string, target *bool) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		e.invalid(name, value, "true or false")
+		return
+	}
+	*target = parsed
+}
+
+// duration читает число единиц unit: CAPTCHA_LOCKOUT_MINUTES=30
+func (e *envReader) duration(name string, unit time.Duration, target *time.Duration) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		e.invalid(name, value, "a number")
+		return
+	}
+	*target = time.Duration(parsed * float64(unit))
+}
+
+// list читает список через запятую. Заданная пустая переменная очищает список
+func (e *envReader) list(name string, target *[]string) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return
+	}
+	*target = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*target = append(*target, item)
+		}
+	}
+}
+
+func (e *envReader) floats(name string, target *[]float64) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return
+	}
+	var values []float64
+	for _, item := range strings.Split(value, ",") {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil {
+			e.invalid(name, item, "a number")
+			return
+		}
+		values = append(values, parsed)
+	}
+	*target = values
+}
+
+func (e *envReader) ids(name string, target *[]int64) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return
+	}
+	var ids []int64
+	for _, item := range strings.Split(value, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(item), 10, 64)
+		if err != nil {
+			e.invalid(name, item, "a Telegram user ID")
+			return
+		}
+		ids = append(ids, id)
+	}
+	*target = ids
+}
+
+func (e *envReader) chats(name string, target *[]subscription.Chat) {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return
+	}
+	var chats []subscription.Chat
+	for _, item := range strings.Split(value, ",") {
+		chat, err := subscription.ParseChat(item)
+		if err != nil {
+			e.problems = append(e.problems, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		chats = append(chats, chat)
+	}
+	*target = chats
+}
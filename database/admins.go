@@ -0,0 +1,242 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"telegram-bot/models"
+	"time"
+)
+
+var ErrLastOwner = errors.New("cannot remove the last owner")
+
+// seedRoles создает роли по умолчанию, не трогая уже настроенные. Владельцу, чья роль
+// создана до появления права на журнал аудита, это право выдается
+func (d *Database) seedRoles() error {
+	for _, role := range models.DefaultRoles {
+		_, err := d.db.Exec(`INSERT OR IGNORE INTO admin_roles (name, permissions) VALUES (?, ?)`,
+			role.Name, strings.Join(role.Permissions, ","))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := d.db.Exec(`UPDATE admin_roles SET permissions = permissions || ',' || ?
+		WHERE name = ? AND ',' || permissions || ',' NOT LIKE '%,' || ? || ',%'`,
+		models.PermViewAudit, models.RoleOwner, models.PermViewAudit)
+	return err
+}
+
+// BootstrapOwners назначает владельцами указанных пользователей, если владельцев еще нет
+func (d *Database) BootstrapOwners(userIDs []int64) error {
+	var owners int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM admins WHERE role = ?`, models.RoleOwner).Scan(&owners); err != nil {
+		return err
+	}
+	if owners > 0 {
+		return nil
+	}
+
+	now := time.Now().Format(timeLayout)
+	for _, userID := range userIDs {
+		_, err := d.db.Exec(`INSERT OR REPLACE INTO admins (user_id, role, granted_by, granted_at) VALUES (?, ?, NULL, ?)`,
+			userID, models.RoleOwner, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Database) CountOwners() (int, error) {
+	var owners int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM admins WHERE role = ?`, models.RoleOwner).Scan(&owners)
+	return owners, err
+}
+
+func splitPermissions(permissions string) []string {
+	var result []string
+	for _, p := range strings.Split(permissions, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// GetAdmin возвращает администратора вместе с правами его роли или nil, если пользователь не администратор
+func (d *Database) GetAdmin(userID int64) (*models.Admin, error) {
+	var admin models.Admin
+	var permissions string
+	var grantedBy sql.NullInt64
+	var grantedAt string
+
+	err := d.db.QueryRow(`SELECT a.user_id, a.role, COALESCE(r.permissions, ''), a.granted_by, a.granted_at
+		FROM admins a LEFT JOIN admin_roles r ON r.name = a.role WHERE a.user_id = ?`, userID).
+		Scan(&admin.UserID, &admin.Role.Name, &permissions, &grantedBy, &grantedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	admin.Role.Permissions = splitPermissions(permissions)
+	if grantedBy.Valid {
+		admin.GrantedBy = &grantedBy.Int64
+	}
+	admin.GrantedAt = parseTime(grantedAt)
+
+	return &admin, nil
+}
+
+func (d *Database) ListAdmins() ([]models.Admin, error) {
+	rows, err := d.db.Query(`SELECT a.user_id, a.role, COALESCE(r.permissions, ''), a.granted_by, a.granted_at
+		FROM admins a LEFT JOIN admin_roles r ON r.name = a.role ORDER BY a.granted_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var admins []models.Admin
+	for rows.Next() {
+		var admin models.Admin
+		var permissions string
+		var grantedBy sql.NullInt64
+		var grantedAt string
+
+		if err := rows.Scan(&admin.UserID, &admin.Role.Name, &permissions, &grantedBy, &grantedAt); err != nil {
+			continue
+		}
+
+		admin.Role.Permissions = splitPermissions(permissions)
+		if grantedBy.Valid {
+			admin.GrantedBy = &grantedBy.Int64
+		}
+		admin.GrantedAt = parseTime(grantedAt)
+
+		admins = append(admins, admin)
+	}
+
+	return admins, nil
+}
+
+// GetAdminIDsWithPermission возвращает администраторов, чья роль включает указанное право
+func (d *Database) GetAdminIDsWithPermission(permission string) ([]int64, error) {
+	admins, err := d.ListAdmins()
+	if err != nil {
+		return nil, err
+	}
+
+	var userIDs []int64
+	for _, admin := range admins {
+		if admin.Role.Has(permission) {
+			userIDs = append(userIDs, admin.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+// SetAdminRole назначает роль пользователю; понижение последнего владельца запрещено
+func (d *Database) SetAdminRole(userID int64, role string, grantedBy int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if role != models.RoleOwner {
+		if err := ensureNotLastOwner(tx, userID); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO admins (user_id, role, granted_by, granted_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET role = excluded.role, granted_by = excluded.granted_by, granted_at = excluded.granted_at`,
+		userID, role, grantedBy, time.Now().Format(timeLayout))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d *Database) RemoveAdmin(userID int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := ensureNotLastOwner(tx, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM admins WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ensureNotLastOwner(tx *sql.Tx, userID int64) error {
+	var role string
+	err := tx.QueryRow(`SELECT role FROM admins WHERE user_id = ?`, userID).Scan(&role)
+	if err == sql.ErrNoRows || (err == nil && role != models.RoleOwner) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var owners int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM admins WHERE role = ?`, models.RoleOwner).Scan(&owners); err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (d *Database) GetRole(name string) (*models.Role, error) {
+	var role models.Role
+	var permissions string
+
+	err := d.db.QueryRow(`SELECT name, permissions FROM admin_roles WHERE name = ?`, name).Scan(&role.Name, &permissions)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	role.Permissions = splitPermissions(permissions)
+	return &role, nil
+}
+
+func (d *Database) ListRoles() ([]models.Role, error) {
+	rows, err := d.db.Query(`SELECT name, permissions FROM admin_roles ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		var role models.Role
+		var permissions string
+		if err := rows.Scan(&role.Name, &permissions); err != nil {
+			continue
+		}
+		role.Permissions = splitPermissions(permissions)
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (d *Database) SetRolePermissions(name string, permissions []string) error {
+	_, err := d.db.Exec(`UPDATE admin_roles SET permissions = ? WHERE name = ?`, strings.Join(permissions, ","), name)
+	return err
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"telegram-bot/models"
+	"time"
+)
+
+func (d *Database) LogAdminAction(actorID int64, action string, targetID *int64, params map[string]interface{}) error {
+	encoded := ""
+	if len(params) > 0 {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		encoded = string(data)
+	}
+
+	_, err := d.db.Exec(`INSERT INTO admin_audit (actor_id, action, target_id, params, created_at) VALUES (?, ?, ?, ?, ?)`,
+		actorID, action, targetID, encoded, time.Now().Format(timeLayout))
+	return err
+}
+
+func auditWhere(filter models.AuditFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.ActorID != 0 {
+		conditions = append(conditions, "actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.TargetID != 0 {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.Format(timeLayout))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// CountAuditLog возвращает количество записей, подходящих под фильтр
+func (d *Database) CountAuditLog(filter models.AuditFilter) (int, error) {
+	where, args := auditWhere(filter)
+
+	var total int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM admin_audit`+where, args...).Scan(&total)
+	return total, err
+}
+
+// GetAuditLog возвращает записи журнала аудита, новые первыми; limit <= 0 означает без ограничения
+func (d *Database) GetAuditLog(filter models.AuditFilter, limit, offset int) ([]models.AuditEntry, error) {
+	where, args := auditWhere(filter)
+
+	query := `SELECT id, actor_id, action, target_id, COALESCE(params, ''), created_at FROM admin_audit` + where + ` ORDER BY id DESC`
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		var targetID sql.NullInt64
+		var params, createdAt string
+
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &targetID, &params, &createdAt); err != nil {
+			continue
+		}
+
+		if targetID.Valid {
+			entry.TargetID = &targetID.Int64
+		}
+		if params != "" {
+			json.Unmarshal([]byte(params), &entry.Params)
+		}
+		entry.CreatedAt = parseTime(createdAt)
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"telegram-bot/models"
+	"time"
+)
+
+var (
+	ErrCampaignExists  = errors.New("campaign already exists")
+	ErrInvalidCampaign = errors.New("invalid campaign slug")
+)
+
+// campaignSlug ограничен символами, допустимыми в параметре ?start= (до 64 символов вместе с префиксом c_)
+var campaignSlug = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,60}$`)
+
+const campaignColumns = `slug, owner_id, reward_amount, welcome_bonus, COALESCE(created_by, 0), created_at`
+
+func scanCampaign(row rowScanner) (*models.Campaign, error) {
+	var campaign models.Campaign
+	var ownerID sql.NullInt64
+	var rewardAmount sql.NullFloat64
+	var createdAt string
+
+	err := row.Scan(&campaign.Slug, &ownerID, &rewardAmount, &campaign.WelcomeBonus, &campaign.CreatedBy, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerID.Valid {
+		campaign.OwnerID = &ownerID.Int64
+	}
+	if rewardAmount.Valid {
+		campaign.RewardAmount = &rewardAmount.Float64
+	}
+	campaign.CreatedAt = parseTime(createdAt)
+
+	return &campaign, nil
+}
+
+// ValidCampaignSlug проверяет, можно ли использовать slug в ссылке кампании
+func ValidCampaignSlug(slug string) bool {
+	return campaignSlug.MatchString(slug)
+}
+
+// CreateCampaign создает кампанию
+func (d *Database) CreateCampaign(campaign *models.Campaign) error {
+	if !ValidCampaignSlug(campaign.Slug) {
+		return ErrInvalidCampaign
+	}
+
+	campaign.CreatedAt = time.Now()
+	result, err := d.db.Exec(`INSERT OR IGNORE INTO campaigns (slug, owner_id, reward_amount, welcome_bonus, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		campaign.Slug, campaign.OwnerID, campaign.RewardAmount, campaign.WelcomeBonus, campaign.CreatedBy,
+		campaign.CreatedAt.Format(timeLayout))
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrCampaignExists
+	}
+	return nil
+}
+
+// GetCampaign возвращает кампанию или nil, если ее нет
+func (d *Database) GetCampaign(slug string) (*models.Campaign, error) {
+	campaign, err := scanCampaign(d.db.QueryRow(`SELECT `+campaignColumns+` FROM campaigns WHERE slug = ?`, slug))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return campaign, err
+}
+
+// CampaignStats возвращает кампании с регистрациями, подтверждениями, выводами и затратами
+func (d *Database) CampaignStats() ([]models.CampaignStats, error) {
+	rows, err := d.db.Query(`
+		SELECT ` + campaignColumns + `,
+			(SELECT COUNT(*) FROM users u WHERE u.campaign = c.slug),
+			(SELECT COUNT(*) FROM users u WHERE u.campaign = c.slug AND u.onboarded_at IS NOT NULL),
+			(SELECT COUNT(*) FROM withdrawals w JOIN users u ON u.user_id = w.user_id
+				WHERE u.campaign = c.slug AND w.status = 'approved'),
+			(SELECT COALESCE(SUM(w.amount), 0) FROM withdrawals w JOIN users u ON u.user_id = w.user_id
+				WHERE u.campaign = c.slug AND w.status = 'approved'),
+			(SELECT COALESCE(SUM(l.amount), 0) FROM ledger l JOIN users u ON u.user_id = l.ref_user_id
+				WHERE u.campaign = c.slug AND l.kind = 'referral_reward')
+			+ (SELECT COALESCE(SUM(l.amount), 0) FROM ledger l JOIN users u ON u.user_id = l.user_id
+				WHERE u.campaign = c.slug AND l.kind = 'welcome_bonus')
+		FROM campaigns c
+		ORDER BY c.created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.CampaignStats
+	for rows.Next() {
+		var s models.CampaignStats
+		var ownerID sql.NullInt64
+		var rewardAmount sql.NullFloat64
+		var createdAt string
+
+		err := rows.Scan(&s.Slug, &ownerID, &rewardAmount, &s.WelcomeBonus, &s.CreatedBy, &createdAt,
+			&s.Signups, &s.Confirmed, &s.Withdrawals, &s.WithdrawnTotal, &s.Cost)
+		if err != nil {
+			return nil, err
+		}
+		if ownerID.Valid {
+			s.OwnerID = &ownerID.Int64
+		}
+		if rewardAmount.Valid {
+			s.RewardAmount = &rewardAmount.Float64
+		}
+		s.CreatedAt = parseTime(createdAt)
+
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// MarkOnboarded отмечает, что пользователь выбрал язык и прошел капчу
+func (d *Database) MarkOnboarded(userID int64) error {
+	_, err := d.db.Exec(`UPDATE users SET onboarded_at = ? WHERE user_id = ? AND onboarded_at IS NULL AND captcha_passed = 1`,
+		time.Now().Format(timeLayout), userID)
+	return err
+}
+
+// CampaignRewardAmount возвращает переопределенную кампанией награду за реферала или nil
+func (d *Database) CampaignRewardAmount(referredID int64) (*float64, error) {
+	var amount sql.NullFloat64
+	err := d.db.QueryRow(`
+		SELECT c.reward_amount
+		FROM users u
+		JOIN campaigns c ON c.slug = u.campaign
+		WHERE u.user_id = ?`, referredID).Scan(&amount)
+	if err == sql.ErrNoRows || (err == nil && !amount.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &amount.Float64, nil
+}
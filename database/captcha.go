@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Результаты проверки ответа на капчу
+const (
+	CaptchaPassed = iota
+	CaptchaWrong
+	CaptchaLocked
+)
+
+// SaveCaptcha запоминает правильный ответ на выданное пользователю задание.
+// Счетчик попыток сбрасывается, только если истекла предыдущая блокировка
+func (d *Database) SaveCaptcha(userID int64, answer string) error {
+	now := time.Now().Format(timeLayout)
+	_, err := d.db.Exec(`
+		INSERT INTO captcha_challenges (user_id, answer, attempts, created_at) VALUES (?, ?, 0, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			answer = excluded.answer,
+			created_at = excluded.created_at,
+			attempts = CASE WHEN locked_until IS NOT NULL AND locked_until <= ? THEN 0 ELSE attempts END,
+			locked_until = CASE WHEN locked_until <= ? THEN NULL ELSE locked_until END`,
+		userID, answer, now, now, now)
+	return err
+}
+
+// CaptchaLockedUntil возвращает время окончания блокировки или nil, если пользователь не заблокирован
+func (d *Database) CaptchaLockedUntil(userID int64) (*time.Time, error) {
+	var lockedUntil sql.NullString
+	err := d.db.QueryRow(`SELECT locked_until FROM captcha_challenges WHERE user_id = ?`, userID).Scan(&lockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	until := parseNullTime(lockedUntil)
+	if until == nil || !until.After(time.Now()) {
+		return nil, nil
+	}
+	return until, nil
+}
+
+// CheckCaptcha проверяет ответ. При правильном ответе пользователь отмечается как прошедший
+// проверку, после maxAttempts неверных ответов подряд он блокируется на lockout
+func (d *Database) CheckCaptcha(userID int64, answer string, maxAttempts int, lockout time.Duration) (int, *time.Time, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var expected string
+	var attempts int
+	var lockedUntil sql.NullString
+	err = tx.QueryRow(`SELECT answer, attempts, locked_until FROM captcha_challenges WHERE user_id = ?`, userID).
+		Scan(&expected, &attempts, &lockedUntil)
+	if err == sql.ErrNoRows {
+		// Задание не выдавалось или уже решено - считаем ответ неверным, будет выдано новое
+		return CaptchaWrong, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	if until := parseNullTime(lockedUntil); until != nil && until.After(now) {
+		return CaptchaLocked, until, nil
+	}
+
+	if answer == expected {
+		if _, err := tx.Exec(`UPDATE users SET captcha_passed = 1 WHERE user_id = ?`, userID); err != nil {
+			return 0, nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM captcha_challenges WHERE user_id = ?`, userID); err != nil {
+			return 0, nil, err
+		}
+		return CaptchaPassed, nil, tx.Commit()
+	}
+
+	attempts++
+	if attempts >= maxAttempts {
+		until := now.Add(lockout)
+		_, err = tx.Exec(`UPDATE captcha_challenges SET attempts = ?, locked_until = ? WHERE user_id = ?`,
+			attempts, until.Format(timeLayout), userID)
+		if err != nil {
+			return 0, nil, err
+		}
+		return CaptchaLocked, &until, tx.Commit()
+	}
+
+	// Одно задание - одна попытка, чтобы ответ нельзя было подобрать перебором кнопок
+	_, err = tx.Exec(`UPDATE captcha_challenges SET attempts = ?, answer = '' WHERE user_id = ?`, attempts, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return CaptchaWrong, nil, tx.Commit()
+}
+
+// MarkCaptchaPassed снимает требование капчи, например после ее отключения в конфигурации
+func (d *Database) MarkCaptchaPassed(userID int64) error {
+	_, err := d.db.Exec(`UPDATE users SET captcha_passed = 1 WHERE user_id = ?`, userID)
+	return err
+}
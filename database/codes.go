@@ -0,0 +1,25 @@
+package database
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// codeAlphabet не содержит похожих символов (0/o, 1/l), чтобы коды было удобно диктовать
+const codeAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+// generateCode возвращает криптографически случайный код, допустимый в параметре ?start=
+func generateCode(length int) (string, error) {
+	code := make([]byte, length)
+	max := big.NewInt(int64(len(codeAlphabet)))
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
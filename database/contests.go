@@ -0,0 +1,272 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"telegram-bot/models"
+	"time"
+)
+
+var (
+	ErrContestActive    = errors.New("another contest is already active")
+	ErrContestNotActive = errors.New("contest is not active")
+)
+
+const contestColumns = `id, metric, prize_pool, winners, starts_at, ends_at, status, COALESCE(created_by, 0)`
+
+func scanContest(row rowScanner) (*models.Contest, error) {
+	var contest models.Contest
+	var startsAt, endsAt string
+
+	err := row.Scan(&contest.ID, &contest.Metric, &contest.PrizePool, &contest.Winners,
+		&startsAt, &endsAt, &contest.Status, &contest.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	contest.StartsAt = parseTime(startsAt)
+	contest.EndsAt = parseTime(endsAt)
+	return &contest, nil
+}
+
+// rankingQuery возвращает запрос рейтинга по метрике за период [since, until) с колонками
+// user_id, value и first_at. Рейтинг строится по таблице referrals, заблокированные
+// пользователи в него не попадают
+func rankingQuery(metric string) (string, error) {
+	switch metric {
+	case models.MetricReferrals:
+		return `
+			SELECT r.referrer_id AS user_id, COUNT(*) AS value, MIN(COALESCE(r.confirmed_at, r.date_added)) AS first_at
+			FROM referrals r
+			JOIN users u ON u.user_id = r.referrer_id
+			WHERE r.status = 'confirmed' AND u.banned = 0
+				AND COALESCE(r.confirmed_at, r.date_added) >= ? AND COALESCE(r.confirmed_at, r.date_added) < ?
+			GROUP BY r.referrer_id`, nil
+	case models.MetricEarned:
+		return `
+			SELECT l.user_id AS user_id, SUM(l.amount) AS value, MIN(l.created_at) AS first_at
+			FROM ledger l
+			JOIN referrals r ON r.referred_id = l.ref_user_id
+			JOIN users u ON u.user_id = l.user_id
+			WHERE l.kind = 'referral_reward' AND u.banned = 0
+				AND l.created_at >= ? AND l.created_at < ?
+			GROUP BY l.user_id`, nil
+	default:
+		return "", fmt.Errorf("unknown ranking metric %q", metric)
+	}
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func ranking(q queryer, metric string, since, until time.Time, limit int) ([]models.RankEntry, error) {
+	query, err := rankingQuery(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	// При равенстве выше тот, кто набрал результат раньше; UserRank считает место так же
+	rows, err := q.Query(`SELECT user_id, value FROM (`+query+`) ORDER BY value DESC, first_at, user_id LIMIT ?`,
+		since.Format(timeLayout), until.Format(timeLayout), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.RankEntry
+	for rows.Next() {
+		entry := models.RankEntry{Rank: len(entries) + 1}
+		if err := rows.Scan(&entry.UserID, &entry.Value); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// Ranking возвращает первые limit мест рейтинга за период
+func (d *Database) Ranking(metric string, since, until time.Time, limit int) ([]models.RankEntry, error) {
+	return ranking(d.db, metric, since, until, limit)
+}
+
+// UserRank возвращает место пользователя в рейтинге за период; nil, если он в рейтинг не попал
+func (d *Database) UserRank(metric string, since, until time.Time, userID int64) (*models.RankEntry, error) {
+	query, err := rankingQuery(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	var value float64
+	var firstAt string
+	err = d.db.QueryRow(`SELECT value, first_at FROM (`+query+`) WHERE user_id = ?`,
+		since.Format(timeLayout), until.Format(timeLayout), userID).Scan(&value, &firstAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Место считается в том же порядке, что и в ranking: значение, время, user_id
+	var higher int
+	err = d.db.QueryRow(`SELECT COUNT(*) FROM (`+query+`)
+		WHERE value > ? OR (value = ? AND (first_at < ? OR (first_at = ? AND user_id < ?)))`,
+		since.Format(timeLayout), until.Format(timeLayout), value, value, firstAt, firstAt, userID).Scan(&higher)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RankEntry{Rank: higher + 1, UserID: userID, Value: value}, nil
+}
+
+// CreateContest запускает конкурс; одновременно может идти только один
+func (d *Database) CreateContest(metric string, prizePool float64, winners int, duration time.Duration, createdBy int64) (*models.Contest, error) {
+	if _, err := rankingQuery(metric); err != nil {
+		return nil, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var active int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM contests WHERE status = ?`, models.ContestActive).Scan(&active); err != nil {
+		return nil, err
+	}
+	if active > 0 {
+		return nil, ErrContestActive
+	}
+
+	now := time.Now()
+	contest := &models.Contest{
+		Metric:    metric,
+		PrizePool: prizePool,
+		Winners:   winners,
+		StartsAt:  now,
+		EndsAt:    now.Add(duration),
+		Status:    models.ContestActive,
+		CreatedBy: createdBy,
+	}
+
+	result, err := tx.Exec(`INSERT INTO contests (metric, prize_pool, winners, starts_at, ends_at, status, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		contest.Metric, contest.PrizePool, contest.Winners, contest.StartsAt.Format(timeLayout),
+		contest.EndsAt.Format(timeLayout), contest.Status, contest.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	contest.ID, _ = result.LastInsertId()
+
+	return contest, tx.Commit()
+}
+
+// GetActiveContest возвращает идущий конкурс или nil
+func (d *Database) GetActiveContest() (*models.Contest, error) {
+	contest, err := scanContest(d.db.QueryRow(`SELECT `+contestColumns+` FROM contests WHERE status = ? ORDER BY id DESC LIMIT 1`,
+		models.ContestActive))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return contest, err
+}
+
+// CancelContest останавливает конкурс без выплаты призов
+func (d *Database) CancelContest(id int64) error {
+	result, err := d.db.Exec(`UPDATE contests SET status = ? WHERE id = ? AND status = ?`,
+		models.ContestCancelled, id, models.ContestActive)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrContestNotActive
+	}
+	return nil
+}
+
+// GetDueContests возвращает активные конкурсы, время которых истекло
+func (d *Database) GetDueContests() ([]models.Contest, error) {
+	rows, err := d.db.Query(`SELECT `+contestColumns+` FROM contests WHERE status = ? AND ends_at <= ?`,
+		models.ContestActive, time.Now().Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contests []models.Contest
+	for rows.Next() {
+		contest, err := scanContest(rows)
+		if err != nil {
+			return nil, err
+		}
+		contests = append(contests, *contest)
+	}
+
+	return contests, rows.Err()
+}
+
+// FinishContest подводит итоги конкурса и начисляет призы победителям через журнал
+func (d *Database) FinishContest(id int64) ([]models.ContestPrize, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	contest, err := scanContest(tx.QueryRow(`SELECT `+contestColumns+` FROM contests WHERE id = ?`, id))
+	if err != nil {
+		return nil, err
+	}
+	if contest.Status != models.ContestActive {
+		return nil, ErrContestNotActive
+	}
+
+	entries, err := ranking(tx, contest.Metric, contest.StartsAt, contest.EndsAt, contest.Winners)
+	if err != nil {
+		return nil, err
+	}
+
+	// Если участников меньше, чем призовых мест, фонд делится между участниками
+	var prizes []models.ContestPrize
+	if len(entries) > 0 {
+		shares := models.PrizeShares(contest.PrizePool, len(entries))
+		note := fmt.Sprintf("contest #%d", contest.ID)
+		for i, entry := range entries {
+			if _, err := addLedgerEntry(tx, entry.UserID, models.LedgerContestPrize, shares[i], nil, nil, note); err != nil {
+				return nil, err
+			}
+			prizes = append(prizes, models.ContestPrize{RankEntry: entry, Amount: shares[i]})
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE contests SET status = ? WHERE id = ?`, models.ContestFinished, id); err != nil {
+		return nil, err
+	}
+
+	return prizes, tx.Commit()
+}
+
+// GetActiveUserLanguages возвращает языки активных пользователей для рассылок
+func (d *Database) GetActiveUserLanguages() (map[int64]string, error) {
+	rows, err := d.db.Query(`SELECT user_id, language FROM users WHERE is_active = 1 AND banned = 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	languages := make(map[int64]string)
+	for rows.Next() {
+		var userID int64
+		var language string
+		if err := rows.Scan(&userID, &language); err != nil {
+			return nil, err
+		}
+		languages[userID] = language
+	}
+
+	return languages, rows.Err()
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"telegram-bot/models"
+	"testing"
+	"time"
+)
+
+func TestFinishContest(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 0)
+	createUser(t, d, 2, nil, 0)
+
+	contest, err := d.CreateContest(models.MetricReferrals, 60, 3, time.Hour, 99)
+	if err != nil {
+		t.Fatalf("CreateContest: %v", err)
+	}
+	if _, err := d.CreateContest(models.MetricReferrals, 10, 1, time.Hour, 99); err != ErrContestActive {
+		t.Errorf("second CreateContest error = %v, want ErrContestActive", err)
+	}
+
+	// У первого два подтвержденных реферала, у второго один, неподтвержденный не считается
+	invite := func(referrerID, userID int64, confirm bool) {
+		createUser(t, d, userID, ptr(referrerID), 0)
+		if confirm {
+			if _, _, err := d.ConfirmReferral(userID, ReferralPolicy{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	invite(1, 10, true)
+	invite(1, 11, true)
+	invite(2, 12, true)
+	invite(2, 13, false)
+
+	prizes, err := d.FinishContest(contest.ID)
+	if err != nil {
+		t.Fatalf("FinishContest: %v", err)
+	}
+
+	// Участников меньше призовых мест - фонд делится между двумя
+	want := []models.ContestPrize{
+		{RankEntry: models.RankEntry{Rank: 1, UserID: 1, Value: 2}, Amount: 40},
+		{RankEntry: models.RankEntry{Rank: 2, UserID: 2, Value: 1}, Amount: 20},
+	}
+	if len(prizes) != len(want) {
+		t.Fatalf("prizes = %+v, want %+v", prizes, want)
+	}
+	for i := range want {
+		if prizes[i] != want[i] {
+			t.Errorf("prizes[%d] = %+v, want %+v", i, prizes[i], want[i])
+		}
+	}
+	assertBalance(t, d, 1, 40)
+	assertBalance(t, d, 2, 20)
+
+	if _, err := d.FinishContest(contest.ID); err != ErrContestNotActive {
+		t.Errorf("second FinishContest error = %v, want ErrContestNotActive", err)
+	}
+	assertBalance(t, d, 1, 40)
+
+	if active, err := d.GetActiveContest(); err != nil || active != nil {
+		t.Errorf("GetActiveContest = %+v, %v, want none", active, err)
+	}
+}
+
+func TestFinishContestWithoutParticipants(t *testing.T) {
+	d := newTestDB(t)
+
+	contest, err := d.CreateContest(models.MetricEarned, 60, 3, time.Hour, 99)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prizes, err := d.FinishContest(contest.ID)
+	if err != nil || len(prizes) != 0 {
+		t.Errorf("FinishContest = %+v, %v", prizes, err)
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"strconv"
 	"telegram-bot/models"
 	"time"
@@ -35,15 +36,146 @@ func (d *Database) createTables() error {
 			balance REAL DEFAULT 0.0,
 			referred_by INTEGER,
 			join_date DATETIME,
-			language TEXT DEFAULT 'ru'
+			language TEXT DEFAULT 'ru',
+			is_active INTEGER DEFAULT 1,
+			blocked_at DATETIME,
+			username TEXT,
+			last_activity DATETIME,
+			banned INTEGER DEFAULT 0,
+			frozen INTEGER DEFAULT 0,
+			no_rewards INTEGER DEFAULT 0,
+			captcha_passed INTEGER DEFAULT 1,
+			campaign TEXT,
+			onboarded_at DATETIME,
+			pending_voucher TEXT
 		)`,
 		`CREATE TABLE IF NOT EXISTS referrals (
 			referrer_id INTEGER,
 			referred_id INTEGER,
 			date_added DATETIME,
+			code TEXT,
+			status TEXT DEFAULT 'confirmed',
+			language_chosen INTEGER DEFAULT 0,
+			confirmed_at DATETIME,
+			fraud_score INTEGER DEFAULT 0,
+			fraud_reasons TEXT DEFAULT '',
+			flagged_at DATETIME,
+			reviewed_by INTEGER,
 			FOREIGN KEY (referrer_id) REFERENCES users (user_id),
 			FOREIGN KEY (referred_id) REFERENCES users (user_id)
 		)`,
+		`CREATE TABLE IF NOT EXISTS ledger (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			amount REAL NOT NULL,
+			balance_after REAL NOT NULL,
+			ref_user_id INTEGER,
+			actor_id INTEGER,
+			note TEXT DEFAULT '',
+			created_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users (user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_ledger_user ON ledger (user_id, id)`,
+		`CREATE TABLE IF NOT EXISTS withdrawals (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			amount REAL NOT NULL,
+			wallet TEXT NOT NULL,
+			status TEXT DEFAULT 'pending',
+			created_at DATETIME,
+			processed_by INTEGER,
+			processed_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users (user_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS admin_audit (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			actor_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			target_id INTEGER,
+			params TEXT DEFAULT '',
+			created_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_admin_audit_actor ON admin_audit (actor_id, id)`,
+		`CREATE TABLE IF NOT EXISTS admin_roles (
+			name TEXT PRIMARY KEY,
+			permissions TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE IF NOT EXISTS admins (
+			user_id INTEGER PRIMARY KEY,
+			role TEXT NOT NULL,
+			granted_by INTEGER,
+			granted_at DATETIME,
+			FOREIGN KEY (role) REFERENCES admin_roles (name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS gift_vouchers (
+			code TEXT PRIMARY KEY,
+			sender_id INTEGER NOT NULL,
+			amount REAL NOT NULL,
+			recipient_id INTEGER,
+			status TEXT NOT NULL DEFAULT 'active',
+			claimed_by INTEGER,
+			claimed_at DATETIME,
+			created_at DATETIME,
+			expires_at DATETIME,
+			FOREIGN KEY (sender_id) REFERENCES users (user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_gift_vouchers_status ON gift_vouchers (status, expires_at)`,
+		`CREATE TABLE IF NOT EXISTS referral_codes (
+			code TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			created_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users (user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_referral_codes_user ON referral_codes (user_id)`,
+		`CREATE TABLE IF NOT EXISTS captcha_challenges (
+			user_id INTEGER PRIMARY KEY,
+			answer TEXT NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			locked_until DATETIME,
+			created_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS contests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			metric TEXT NOT NULL,
+			prize_pool REAL NOT NULL,
+			winners INTEGER NOT NULL,
+			starts_at DATETIME,
+			ends_at DATETIME,
+			status TEXT NOT NULL DEFAULT 'active',
+			created_by INTEGER
+		)`,
+		`CREATE TABLE IF NOT EXISTS campaigns (
+			slug TEXT PRIMARY KEY,
+			owner_id INTEGER,
+			reward_amount REAL,
+			welcome_bonus REAL DEFAULT 0,
+			created_by INTEGER,
+			created_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_users_campaign ON users (campaign)`,
+		`CREATE TABLE IF NOT EXISTS welcome_bonuses (
+			user_id INTEGER PRIMARY KEY,
+			amount REAL NOT NULL,
+			source TEXT NOT NULL,
+			created_at DATETIME,
+			unlocked_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users (user_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS text_overrides (
+			lang TEXT NOT NULL,
+			key TEXT NOT NULL,
+			text TEXT NOT NULL,
+			updated_by INTEGER,
+			updated_at DATETIME,
+			PRIMARY KEY (lang, key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_activity_days (
+			user_id INTEGER NOT NULL,
+			day TEXT NOT NULL,
+			PRIMARY KEY (user_id, day)
+		)`,
 	}
 
 	for _, query := range queries {
@@ -52,21 +184,116 @@ func (d *Database) createTables() error {
 		}
 	}
 
-	return nil
+	if err := d.migrate(); err != nil {
+		return err
+	}
+
+	return d.seedRoles()
+}
+
+// migrate добавляет колонки, появившиеся после создания таблиц, в уже существующие базы
+func (d *Database) migrate() error {
+	columns := []struct {
+		table, name, definition string
+	}{
+		{"users", "is_active", "INTEGER DEFAULT 1"},
+		{"users", "blocked_at", "DATETIME"},
+		{"users", "username", "TEXT"},
+		{"users", "last_activity", "DATETIME"},
+		{"users", "banned", "INTEGER DEFAULT 0"},
+		{"users", "frozen", "INTEGER DEFAULT 0"},
+		{"users", "no_rewards", "INTEGER DEFAULT 0"},
+		// Пользователи, зарегистрированные до появления капчи, считаются прошедшими ее
+		{"users", "captcha_passed", "INTEGER DEFAULT 1"},
+		{"users", "campaign", "TEXT"},
+		{"users", "onboarded_at", "DATETIME"},
+		{"users", "pending_voucher", "TEXT"},
+		{"withdrawals", "processed_by", "INTEGER"},
+		{"withdrawals", "processed_at", "DATETIME"},
+		{"referrals", "code", "TEXT"},
+		// Рефералы, появившиеся до подтверждения, уже оплачены и считаются подтвержденными
+		{"referrals", "status", "TEXT DEFAULT 'confirmed'"},
+		{"referrals", "language_chosen", "INTEGER DEFAULT 0"},
+		{"referrals", "confirmed_at", "DATETIME"},
+		{"referrals", "fraud_score", "INTEGER DEFAULT 0"},
+		{"referrals", "fraud_reasons", "TEXT DEFAULT ''"},
+		{"referrals", "flagged_at", "DATETIME"},
+		{"referrals", "reviewed_by", "INTEGER"},
+	}
+
+	for _, column := range columns {
+		if err := d.addColumnIfMissing(column.table, column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	return d.uniqueDefaultReferralCodes()
 }
 
-func (d *Database) GetUser(userID int64) (*models.User, error) {
+// uniqueDefaultReferralCodes оставляет у пользователя один основной код и запрещает
+// создавать второй. Лишние основные коды, появившиеся при одновременных запросах,
+// становятся именованными, чтобы разосланные ссылки продолжали работать
+func (d *Database) uniqueDefaultReferralCodes() error {
+	_, err := d.db.Exec(`UPDATE referral_codes SET name = code
+		WHERE name = '' AND rowid NOT IN (SELECT MIN(rowid) FROM referral_codes WHERE name = '' GROUP BY user_id)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_referral_codes_default ON referral_codes (user_id) WHERE name = ''`)
+	return err
+}
+
+func (d *Database) addColumnIfMissing(table, column, definition string) error {
+	rows, err := d.db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = d.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	return err
+}
+
+const (
+	timeLayout  = "2006-01-02 15:04:05"
+	userColumns = `user_id, COALESCE(username, ''), balance, referred_by, join_date, language, is_active, blocked_at, banned, frozen, no_rewards, last_activity, captcha_passed, COALESCE(campaign, '')`
+)
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
 	var user models.User
 	var referredBy sql.NullInt64
 	var joinDate string
+	var blockedAt, lastActivity sql.NullString
 
-	query := `SELECT user_id, balance, referred_by, join_date, language FROM users WHERE user_id = ?`
-	err := d.db.QueryRow(query, userID).Scan(&user.UserID, &user.Balance, &referredBy, &joinDate, &user.Language)
-
+	err := row.Scan(&user.UserID, &user.Username, &user.Balance, &referredBy, &joinDate, &user.Language,
+		&user.IsActive, &blockedAt, &user.Banned, &user.Frozen, &user.NoRewards, &lastActivity, &user.CaptchaPassed, &user.Campaign)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
 		return nil, err
 	}
 
@@ -74,28 +301,98 @@ func (d *Database) GetUser(userID int64) (*models.User, error) {
 		user.ReferredBy = &referredBy.Int64
 	}
 
-	user.JoinDate, _ = time.Parse("2006-01-02 15:04:05", joinDate)
+	user.JoinDate = parseTime(joinDate)
+
+	user.BlockedAt = parseNullTime(blockedAt)
+	user.LastActivity = parseNullTime(lastActivity)
+
+	return &user, nil
+}
+
+// parseTime разбирает время, записанное в формате timeLayout. Столбцы DATETIME драйвер
+// возвращает как time.Time, и при сканировании в строку они приходят в формате RFC 3339.
+// Время хранится без зоны и считается локальным
+func parseTime(value string) time.Time {
+	for _, layout := range []string{timeLayout, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+		}
+	}
+	return time.Time{}
+}
+
+func parseNullTime(value sql.NullString) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t := parseTime(value.String)
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func (d *Database) GetUser(userID int64) (*models.User, error) {
+	user, err := scanUser(d.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE user_id = ?`, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
 
 	// Получаем рефералов
-	referralQuery := `SELECT referred_id FROM referrals WHERE referrer_id = ?`
+	referralQuery := `SELECT referred_id, status FROM referrals WHERE referrer_id = ?`
 	rows, err := d.db.Query(referralQuery, userID)
 	if err != nil {
-		return &user, nil
+		return user, nil
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var referralID int64
-		if err := rows.Scan(&referralID); err == nil {
+		var status string
+		if err := rows.Scan(&referralID, &status); err == nil {
 			user.Referrals = append(user.Referrals, referralID)
+			switch status {
+			case models.ReferralPending, models.ReferralHeld:
+				user.PendingReferrals++
+			case models.ReferralRejected:
+				user.RejectedReferrals++
+			}
 		}
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-func (d *Database) CreateUser(userID int64, referredBy *int64, rewardAmount float64) error {
-	joinDate := time.Now().Format("2006-01-02 15:04:05")
+// NewUser - параметры регистрации пользователя
+type NewUser struct {
+	UserID     int64
+	ReferredBy *int64
+	// Язык интерфейса; до выбора языка - язык по умолчанию
+	Language string
+	// Код из ссылки, по которой пришел пользователь (пустой для старых числовых ссылок)
+	ReferralCode string
+	// Рекламная кампания, по ссылке которой пришел пользователь
+	Campaign *models.Campaign
+	// Нужно ли пройти капчу до активации аккаунта
+	CaptchaRequired bool
+	// Приветственный бонус, заблокированный до UnlockWelcomeBonus (0 - без бонуса)
+	WelcomeBonus float64
+	// Откуда бонус: referral или campaign <slug>
+	WelcomeBonusSource string
+}
+
+// CreateUser создает пользователя. Реферал создается неподтвержденным, награда
+// начисляется позже в ConfirmReferral. Приветственный бонус создается заблокированным
+func (d *Database) CreateUser(params NewUser) error {
+	joinDate := time.Now().Format(timeLayout)
+
+	var campaign sql.NullString
+	if params.Campaign != nil {
+		campaign = sql.NullString{String: params.Campaign.Slug, Valid: true}
+	}
 
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -103,22 +400,24 @@ func (d *Database) CreateUser(userID int64, referredBy *int64, rewardAmount floa
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(`INSERT INTO users (user_id, balance, referred_by, join_date, language) VALUES (?, ?, ?, ?, ?)`,
-		userID, 0.0, referredBy, joinDate, "ru")
+	_, err = tx.Exec(`INSERT INTO users (user_id, balance, referred_by, join_date, language, captcha_passed, campaign) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		params.UserID, 0.0, params.ReferredBy, joinDate, params.Language, !params.CaptchaRequired, campaign)
 	if err != nil {
 		return err
 	}
 
-	if referredBy != nil {
+	if params.ReferredBy != nil {
 		// Добавляем запись о реферале
-		_, err = tx.Exec(`INSERT INTO referrals (referrer_id, referred_id, date_added) VALUES (?, ?, ?)`,
-			*referredBy, userID, joinDate)
+		_, err = tx.Exec(`INSERT INTO referrals (referrer_id, referred_id, date_added, code, status) VALUES (?, ?, ?, NULLIF(?, ''), ?)`,
+			*params.ReferredBy, params.UserID, joinDate, params.ReferralCode, models.ReferralPending)
 		if err != nil {
 			return err
 		}
+	}
 
-		// Начисляем бонус рефереру
-		_, err = tx.Exec(`UPDATE users SET balance = balance + ? WHERE user_id = ?`, rewardAmount, *referredBy)
+	if params.WelcomeBonus > 0 {
+		_, err = tx.Exec(`INSERT INTO welcome_bonuses (user_id, amount, source, created_at) VALUES (?, ?, ?, ?)`,
+			params.UserID, params.WelcomeBonus, params.WelcomeBonusSource, joinDate)
 		if err != nil {
 			return err
 		}
@@ -127,8 +426,48 @@ func (d *Database) CreateUser(userID int64, referredBy *int64, rewardAmount floa
 	return tx.Commit()
 }
 
-func (d *Database) UpdateUserBalance(userID int64, newBalance float64) error {
-	_, err := d.db.Exec(`UPDATE users SET balance = ? WHERE user_id = ?`, newBalance, userID)
+// FindUserByUsername ищет пользователя по @username без учета регистра
+func (d *Database) FindUserByUsername(username string) (*models.User, error) {
+	var userID int64
+	err := d.db.QueryRow(`SELECT user_id FROM users WHERE username = ? COLLATE NOCASE`, username).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return d.GetUser(userID)
+}
+
+// TouchUser запоминает актуальный username, время последней активности и день активности.
+// newDay сообщает, что это первая активность пользователя за текущий день
+func (d *Database) TouchUser(userID int64, username string) (newDay bool, err error) {
+	now := time.Now()
+	_, err = d.db.Exec(`UPDATE users SET username = ?, last_activity = ? WHERE user_id = ?`,
+		username, now.Format(timeLayout), userID)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := d.db.Exec(`INSERT OR IGNORE INTO user_activity_days (user_id, day)
+		SELECT user_id, ? FROM users WHERE user_id = ?`, now.Format("2006-01-02"), userID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected == 1, nil
+}
+
+// SetUserRestriction включает или снимает одно из ограничений models.Restriction*
+func (d *Database) SetUserRestriction(userID int64, restriction string, enabled bool) error {
+	switch restriction {
+	case models.RestrictionBanned, models.RestrictionFrozen, models.RestrictionNoRewards:
+	default:
+		return fmt.Errorf("unknown restriction %q", restriction)
+	}
+
+	_, err := d.db.Exec(fmt.Sprintf(`UPDATE users SET %s = ? WHERE user_id = ?`, restriction), enabled, userID)
 	return err
 }
 
@@ -155,6 +494,37 @@ func (d *Database) GetAllUserIDs() ([]int64, error) {
 	return userIDs, nil
 }
 
+// GetActiveUserIDs возвращает пользователей, которые не заблокировали бота
+func (d *Database) GetActiveUserIDs() ([]int64, error) {
+	rows, err := d.db.Query(`SELECT user_id FROM users WHERE is_active = 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err == nil {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	return userIDs, nil
+}
+
+// SetUserActive отмечает, что пользователь заблокировал бота или снова стал доступен
+func (d *Database) SetUserActive(userID int64, active bool) error {
+	if active {
+		_, err := d.db.Exec(`UPDATE users SET is_active = 1, blocked_at = NULL WHERE user_id = ?`, userID)
+		return err
+	}
+
+	_, err := d.db.Exec(`UPDATE users SET is_active = 0, blocked_at = ? WHERE user_id = ? AND is_active = 1`,
+		time.Now().Format(timeLayout), userID)
+	return err
+}
+
 func (d *Database) GetStats() (*models.Stats, error) {
 	var stats models.Stats
 
@@ -164,10 +534,17 @@ func (d *Database) GetStats() (*models.Stats, error) {
 		return nil, err
 	}
 
+	// Активные и заблокировавшие бота
+	err = d.db.QueryRow(`SELECT COUNT(*) FROM users WHERE is_active = 1`).Scan(&stats.Active)
+	if err != nil {
+		return nil, err
+	}
+	stats.Blocked = stats.Total - stats.Active
+
 	now := time.Now()
-	dayAgo := now.Add(-24 * time.Hour).Format("2006-01-02 15:04:05")
-	weekAgo := now.Add(-7 * 24 * time.Hour).Format("2006-01-02 15:04:05")
-	monthAgo := now.Add(-30 * 24 * time.Hour).Format("2006-01-02 15:04:05")
+	dayAgo := now.Add(-24 * time.Hour).Format(timeLayout)
+	weekAgo := now.Add(-7 * 24 * time.Hour).Format(timeLayout)
+	monthAgo := now.Add(-30 * 24 * time.Hour).Format(timeLayout)
 
 	// За последние 24 часа
 	err = d.db.QueryRow(`SELECT COUNT(*) FROM users WHERE join_date >= ?`, dayAgo).Scan(&stats.Day)
@@ -191,7 +568,7 @@ func (d *Database) GetStats() (*models.Stats, error) {
 }
 
 func (d *Database) ExportAllUsers() (map[string]*models.User, error) {
-	rows, err := d.db.Query(`SELECT user_id, balance, referred_by, join_date, language FROM users`)
+	rows, err := d.db.Query(`SELECT ` + userColumns + ` FROM users`)
 	if err != nil {
 		return nil, err
 	}
@@ -200,21 +577,11 @@ func (d *Database) ExportAllUsers() (map[string]*models.User, error) {
 	users := make(map[string]*models.User)
 
 	for rows.Next() {
-		var user models.User
-		var referredBy sql.NullInt64
-		var joinDate string
-
-		err := rows.Scan(&user.UserID, &user.Balance, &referredBy, &joinDate, &user.Language)
+		user, err := scanUser(rows)
 		if err != nil {
 			continue
 		}
 
-		if referredBy.Valid {
-			user.ReferredBy = &referredBy.Int64
-		}
-
-		user.JoinDate, _ = time.Parse("2006-01-02 15:04:05", joinDate)
-
 		// Получаем рефералов для каждого пользователя
 		referralRows, err := d.db.Query(`SELECT referred_id FROM referrals WHERE referrer_id = ?`, user.UserID)
 		if err == nil {
@@ -227,7 +594,7 @@ func (d *Database) ExportAllUsers() (map[string]*models.User, error) {
 			referralRows.Close()
 		}
 
-		users[strconv.FormatInt(user.UserID, 10)] = &user
+		users[strconv.FormatInt(user.UserID, 10)] = user
 	}
 
 	return users, nil
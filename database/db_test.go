@@ -0,0 +1,52 @@
+package database
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB создает базу во временном файле, удаляемом после теста
+func newTestDB(t *testing.T) *Database {
+	t.Helper()
+	d, err := New(filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+// createUser создает пользователя, прошедшего капчу, с начальным балансом
+func createUser(t *testing.T, d *Database, userID int64, referredBy *int64, balance float64) {
+	t.Helper()
+	err := d.CreateUser(NewUser{UserID: userID, ReferredBy: referredBy, Language: "en"})
+	if err != nil {
+		t.Fatalf("CreateUser(%d): %v", userID, err)
+	}
+	if balance > 0 {
+		if _, _, err := d.AdjustBalance(userID, "=", balance, 1, "test"); err != nil {
+			t.Fatalf("AdjustBalance(%d): %v", userID, err)
+		}
+	}
+}
+
+func balanceOf(t *testing.T, d *Database, userID int64) float64 {
+	t.Helper()
+	user, err := d.GetUser(userID)
+	if err != nil || user == nil {
+		t.Fatalf("GetUser(%d) = %v, %v", userID, user, err)
+	}
+	return user.Balance
+}
+
+func assertBalance(t *testing.T, d *Database, userID int64, want float64) {
+	t.Helper()
+	if got := balanceOf(t, d, userID); math.Abs(got-want) > 1e-9 {
+		t.Errorf("balance of %d = %v, want %v", userID, got, want)
+	}
+}
+
+func ptr(id int64) *int64 {
+	return &id
+}
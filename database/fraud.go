@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"telegram-bot/models"
+	"time"
+)
+
+const (
+	// Рефералы с Telegram ID ближе этого расстояния, скорее всего, зарегистрированы пачкой
+	nearbyIDDistance = 1000
+	// Реферер считается "свежим", если зарегистрировался не раньше чем за это время до реферала
+	freshAccountAge = 48 * time.Hour
+	// Ограничение на глубину обхода цепочки рефереров
+	maxChainWalk = 20
+)
+
+var ErrReferralNotHeld = errors.New("referral is not held for review")
+
+// referralSignals собирает признаки реферала для антифрода
+func referralSignals(tx *sql.Tx, referredID int64) (models.ReferralSignals, error) {
+	signals := models.ReferralSignals{ReferredID: referredID}
+
+	var dateAdded string
+	var languageChosen int
+	err := tx.QueryRow(`SELECT referrer_id, date_added, language_chosen FROM referrals WHERE referred_id = ?`, referredID).
+		Scan(&signals.ReferrerID, &dateAdded, &languageChosen)
+	if err != nil {
+		return signals, err
+	}
+	added := parseTime(dateAdded)
+	dateAdded = added.Format(timeLayout)
+
+	err = tx.QueryRow(`SELECT COUNT(*) FROM referrals
+		WHERE referrer_id = ? AND date_added >= datetime(?, '-1 hour') AND date_added <= ?`,
+		signals.ReferrerID, dateAdded, dateAdded).Scan(&signals.RecentReferrals)
+	if err != nil {
+		return signals, err
+	}
+
+	err = tx.QueryRow(`SELECT COUNT(*) FROM referrals
+		WHERE referrer_id = ? AND referred_id != ? AND ABS(referred_id - ?) <= ?`,
+		signals.ReferrerID, referredID, referredID, nearbyIDDistance).Scan(&signals.NearbyIDs)
+	if err != nil {
+		return signals, err
+	}
+
+	// Взаимодействием считаем ручной выбор языка или активность больше чем в один день
+	var activeDays int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM user_activity_days WHERE user_id = ?`, referredID).Scan(&activeDays)
+	if err != nil {
+		return signals, err
+	}
+	signals.Interacted = languageChosen == models.LanguagePicked || activeDays > 1
+
+	freshSince := added.Add(-freshAccountAge)
+
+	// Поднимаемся по цепочке: считаем недавно зарегистрированных рефереров подряд и ищем циклы
+	visited := map[int64]bool{referredID: true}
+	current := signals.ReferrerID
+	countingFresh := true
+	for step := 0; step < maxChainWalk; step++ {
+		if visited[current] {
+			signals.Cycle = true
+			break
+		}
+		visited[current] = true
+
+		var joinDate string
+		var referredBy sql.NullInt64
+		err := tx.QueryRow(`SELECT join_date, referred_by FROM users WHERE user_id = ?`, current).Scan(&joinDate, &referredBy)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return signals, err
+		}
+
+		joined := parseTime(joinDate)
+		if countingFresh && !joined.Before(freshSince) {
+			signals.FreshChainDepth++
+		} else {
+			countingFresh = false
+		}
+
+		if !referredBy.Valid {
+			break
+		}
+		current = referredBy.Int64
+	}
+
+	return signals, nil
+}
+
+// ReleaseHeldReferral подтверждает задержанного реферала по решению администратора и начисляет награды
+func (d *Database) ReleaseHeldReferral(referredID, actorID int64, rewards []float64) ([]models.ReferralReward, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE referrals SET status = ?, confirmed_at = ?, reviewed_by = ? WHERE referred_id = ? AND status = ?`,
+		models.ReferralConfirmed, time.Now().Format(timeLayout), actorID, referredID, models.ReferralHeld)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, ErrReferralNotHeld
+	}
+
+	paid, err := payReferralRewards(tx, referredID, rewards)
+	if err != nil {
+		return nil, err
+	}
+
+	return paid, tx.Commit()
+}
+
+// RejectHeldReferral отклоняет задержанного реферала, награда за него не начисляется
+func (d *Database) RejectHeldReferral(referredID, actorID int64) error {
+	result, err := d.db.Exec(`UPDATE referrals SET status = ?, reviewed_by = ? WHERE referred_id = ? AND status = ?`,
+		models.ReferralRejected, actorID, referredID, models.ReferralHeld)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrReferralNotHeld
+	}
+	return nil
+}
+
+// FlaggedReferrers возвращает рефереров, чьи рефералы были задержаны антифродом начиная с since
+func (d *Database) FlaggedReferrers(since time.Time) ([]models.FlaggedReferrer, error) {
+	rows, err := d.db.Query(`
+		SELECT referrer_id, COUNT(*), MAX(fraud_score)
+		FROM referrals
+		WHERE flagged_at >= ?
+		GROUP BY referrer_id
+		ORDER BY COUNT(*) DESC, MAX(fraud_score) DESC`, since.Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var referrers []models.FlaggedReferrer
+	for rows.Next() {
+		var referrer models.FlaggedReferrer
+		if err := rows.Scan(&referrer.ReferrerID, &referrer.Flagged, &referrer.MaxScore); err != nil {
+			return nil, err
+		}
+		referrers = append(referrers, referrer)
+	}
+
+	return referrers, rows.Err()
+}
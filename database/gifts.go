@@ -0,0 +1,97 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"telegram-bot/models"
+	"time"
+)
+
+var (
+	ErrGiftDailyLimit = errors.New("daily gift limit exceeded")
+	ErrGiftMinBalance = errors.New("gift would drop balance below the minimum")
+	// Ограничения могли измениться, пока пользователь заполнял подарок
+	ErrGiftSenderRestricted    = errors.New("gift sender is frozen or banned")
+	ErrGiftRecipientRestricted = errors.New("gift recipient is banned")
+)
+
+// GiftLimits - ограничения на подарки, проверяемые внутри транзакции перевода
+type GiftLimits struct {
+	DailyLimit float64
+	MinBalance float64
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func giftedToday(q queryRower, userID int64) (float64, error) {
+	var total float64
+	err := q.QueryRow(`SELECT COALESCE(-SUM(amount), 0) FROM ledger WHERE user_id = ? AND kind IN (?, ?) AND created_at >= ?`,
+		userID, models.LedgerGiftSent, models.LedgerVoucherCreated, startOfDay(time.Now()).Format(timeLayout)).Scan(&total)
+	return total, err
+}
+
+// startOfDay возвращает полночь дня t: дневной лимит сбрасывается в начале календарного дня
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// GiftedToday возвращает сумму подарков и ваучеров, отправленных пользователем с начала текущего дня
+func (d *Database) GiftedToday(userID int64) (float64, error) {
+	return giftedToday(d.db, userID)
+}
+
+// TransferGift атомарно переводит сумму между пользователями, записывая обе стороны в журнал
+func (d *Database) TransferGift(senderID, recipientID int64, amount float64, note string, limits GiftLimits) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var balance float64
+	var frozen, banned bool
+	err = tx.QueryRow(`SELECT balance, frozen, banned FROM users WHERE user_id = ?`, senderID).Scan(&balance, &frozen, &banned)
+	if err != nil {
+		return err
+	}
+	if frozen || banned {
+		return ErrGiftSenderRestricted
+	}
+
+	err = tx.QueryRow(`SELECT banned FROM users WHERE user_id = ?`, recipientID).Scan(&banned)
+	if err == sql.ErrNoRows || (err == nil && banned) {
+		return ErrGiftRecipientRestricted
+	}
+	if err != nil {
+		return err
+	}
+
+	if balance < amount {
+		return ErrInsufficientFunds
+	}
+	if balance-amount < limits.MinBalance {
+		return ErrGiftMinBalance
+	}
+
+	if limits.DailyLimit > 0 {
+		sent, err := giftedToday(tx, senderID)
+		if err != nil {
+			return err
+		}
+		if sent+amount > limits.DailyLimit {
+			return ErrGiftDailyLimit
+		}
+	}
+
+	if _, err := addLedgerEntry(tx, senderID, models.LedgerGiftSent, -amount, &recipientID, nil, note); err != nil {
+		return err
+	}
+	if _, err := addLedgerEntry(tx, recipientID, models.LedgerGiftReceived, amount, &senderID, nil, note); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
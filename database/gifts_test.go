@@ -0,0 +1,95 @@
+package database
+
+import (
+	"errors"
+	"telegram-bot/models"
+	"testing"
+)
+
+func TestTransferGift(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 100)
+	createUser(t, d, 2, nil, 0)
+
+	if err := d.TransferGift(1, 2, 30, "thanks", GiftLimits{}); err != nil {
+		t.Fatalf("TransferGift: %v", err)
+	}
+	assertBalance(t, d, 1, 70)
+	assertBalance(t, d, 2, 30)
+
+	sent, err := d.GiftedToday(1)
+	if err != nil || sent != 30 {
+		t.Errorf("GiftedToday = %v, %v, want 30", sent, err)
+	}
+}
+
+func TestTransferGiftLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  float64
+		limits  GiftLimits
+		prepare func(t *testing.T, d *Database)
+		want    error
+	}{
+		{name: "insufficient funds", amount: 150, want: ErrInsufficientFunds},
+		{name: "min balance", amount: 60, limits: GiftLimits{MinBalance: 50}, want: ErrGiftMinBalance},
+		{
+			name:   "daily limit",
+			amount: 30,
+			limits: GiftLimits{DailyLimit: 50},
+			prepare: func(t *testing.T, d *Database) {
+				if err := d.TransferGift(1, 2, 30, "", GiftLimits{}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: ErrGiftDailyLimit,
+		},
+		{
+			name:   "frozen sender",
+			amount: 10,
+			prepare: func(t *testing.T, d *Database) {
+				if err := d.SetUserRestriction(1, models.RestrictionFrozen, true); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: ErrGiftSenderRestricted,
+		},
+		{
+			name:   "banned recipient",
+			amount: 10,
+			prepare: func(t *testing.T, d *Database) {
+				if err := d.SetUserRestriction(2, models.RestrictionBanned, true); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: ErrGiftRecipientRestricted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDB(t)
+			createUser(t, d, 1, nil, 100)
+			createUser(t, d, 2, nil, 0)
+			if tt.prepare != nil {
+				tt.prepare(t, d)
+			}
+			before := balanceOf(t, d, 1)
+
+			if err := d.TransferGift(1, 2, tt.amount, "", tt.limits); !errors.Is(err, tt.want) {
+				t.Fatalf("TransferGift error = %v, want %v", err, tt.want)
+			}
+			assertBalance(t, d, 1, before)
+		})
+	}
+}
+
+func TestTransferGiftUnknownRecipient(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 100)
+
+	if err := d.TransferGift(1, 2, 10, "", GiftLimits{}); err != ErrGiftRecipientRestricted {
+		t.Fatalf("TransferGift error = %v, want ErrGiftRecipientRestricted", err)
+	}
+	assertBalance(t, d, 1, 100)
+}
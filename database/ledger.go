@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"telegram-bot/models"
+	"time"
+)
+
+var ErrNegativeBalance = errors.New("balance cannot become negative")
+
+// addLedgerEntry изменяет баланс пользователя и записывает операцию в журнал в рамках транзакции
+func addLedgerEntry(tx *sql.Tx, userID int64, kind string, amount float64, refUserID, actorID *int64, note string) (float64, error) {
+	if _, err := tx.Exec(`UPDATE users SET balance = balance + ? WHERE user_id = ?`, amount, userID); err != nil {
+		return 0, err
+	}
+
+	var balance float64
+	if err := tx.QueryRow(`SELECT balance FROM users WHERE user_id = ?`, userID).Scan(&balance); err != nil {
+		return 0, err
+	}
+
+	_, err := tx.Exec(`INSERT INTO ledger (user_id, kind, amount, balance_after, ref_user_id, actor_id, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		userID, kind, amount, balance, refUserID, actorID, note, time.Now().Format(timeLayout))
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// ApplyBalanceOp вычисляет новый баланс для операции +, - или =
+func ApplyBalanceOp(balance float64, op string, value float64) float64 {
+	switch op {
+	case "+":
+		return balance + value
+	case "-":
+		return balance - value
+	default:
+		return value
+	}
+}
+
+// AdjustBalance применяет ручную корректировку администратора и записывает ее в журнал
+func (d *Database) AdjustBalance(userID int64, op string, value float64, actorID int64, reason string) (float64, float64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var oldBalance float64
+	if err := tx.QueryRow(`SELECT balance FROM users WHERE user_id = ?`, userID).Scan(&oldBalance); err != nil {
+		return 0, 0, err
+	}
+
+	newBalance := ApplyBalanceOp(oldBalance, op, value)
+	if newBalance < 0 {
+		return 0, 0, ErrNegativeBalance
+	}
+
+	newBalance, err = addLedgerEntry(tx, userID, models.LedgerAdminAdjust, newBalance-oldBalance, nil, &actorID, reason)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return oldBalance, newBalance, nil
+}
+
+// GetLedger возвращает последние операции пользователя, новые первыми
+func (d *Database) GetLedger(userID int64, limit int) ([]models.LedgerEntry, error) {
+	rows, err := d.db.Query(`SELECT id, user_id, kind, amount, balance_after, ref_user_id, actor_id, COALESCE(note, ''), created_at
+		FROM ledger WHERE user_id = ? ORDER BY id DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LedgerEntry
+	for rows.Next() {
+		var entry models.LedgerEntry
+		var refUserID, actorID sql.NullInt64
+		var createdAt string
+
+		err := rows.Scan(&entry.ID, &entry.UserID, &entry.Kind, &entry.Amount, &entry.BalanceAfter,
+			&refUserID, &actorID, &entry.Note, &createdAt)
+		if err != nil {
+			continue
+		}
+
+		if refUserID.Valid {
+			entry.RefUserID = &refUserID.Int64
+		}
+		if actorID.Valid {
+			entry.ActorID = &actorID.Int64
+		}
+		entry.CreatedAt = parseTime(createdAt)
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
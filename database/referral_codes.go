@@ -0,0 +1,121 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"telegram-bot/models"
+	"time"
+)
+
+const referralCodeLength = 8
+
+var ErrReferralCodeLimit = errors.New("referral code limit reached")
+
+// insertReferralCode создает код, повторяя генерацию при маловероятном совпадении
+func (d *Database) insertReferralCode(userID int64, name string) (*models.ReferralCode, error) {
+	now := time.Now()
+
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := generateCode(referralCodeLength)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := d.db.Exec(`INSERT OR IGNORE INTO referral_codes (code, user_id, name, created_at) VALUES (?, ?, ?, ?)`,
+			code, userID, name, now.Format(timeLayout))
+		if err != nil {
+			return nil, err
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			return &models.ReferralCode{Code: code, UserID: userID, Name: name, CreatedAt: now}, nil
+		}
+
+		// Основной код мог создать параллельный запрос: уникальный индекс не дает
+		// вставить второй, поэтому возвращаем уже существующий
+		if name == "" {
+			existing, err := d.defaultReferralCode(userID)
+			if err == nil {
+				return &models.ReferralCode{Code: existing, UserID: userID}, nil
+			}
+			if err != sql.ErrNoRows {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, errors.New("failed to generate unique referral code")
+}
+
+func (d *Database) defaultReferralCode(userID int64) (string, error) {
+	var code string
+	err := d.db.QueryRow(`SELECT code FROM referral_codes WHERE user_id = ? AND name = ''`, userID).Scan(&code)
+	return code, err
+}
+
+// GetDefaultReferralCode возвращает основной код пользователя, создавая его при первом обращении
+func (d *Database) GetDefaultReferralCode(userID int64) (string, error) {
+	code, err := d.defaultReferralCode(userID)
+	if err != sql.ErrNoRows {
+		return code, err
+	}
+
+	created, err := d.insertReferralCode(userID, "")
+	if err != nil {
+		return "", err
+	}
+	return created.Code, nil
+}
+
+// CreateReferralCode создает дополнительный именованный код, не более limit на пользователя
+func (d *Database) CreateReferralCode(userID int64, name string, limit int) (*models.ReferralCode, error) {
+	var count int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM referral_codes WHERE user_id = ? AND name != ''`, userID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count >= limit {
+		return nil, ErrReferralCodeLimit
+	}
+
+	return d.insertReferralCode(userID, name)
+}
+
+// ResolveReferralCode возвращает владельца кода или nil, если код не найден
+func (d *Database) ResolveReferralCode(code string) (*int64, error) {
+	var userID int64
+	err := d.db.QueryRow(`SELECT user_id FROM referral_codes WHERE code = ?`, code).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &userID, nil
+}
+
+// ListReferralCodes возвращает коды пользователя с количеством приглашенных по каждому
+func (d *Database) ListReferralCodes(userID int64) ([]models.ReferralCode, error) {
+	rows, err := d.db.Query(`
+		SELECT c.code, c.name, c.created_at, COUNT(r.referred_id)
+		FROM referral_codes c
+		LEFT JOIN referrals r ON r.code = c.code
+		WHERE c.user_id = ?
+		GROUP BY c.code
+		ORDER BY c.name != '', c.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []models.ReferralCode
+	for rows.Next() {
+		code := models.ReferralCode{UserID: userID}
+		var createdAt string
+		if err := rows.Scan(&code.Code, &code.Name, &createdAt, &code.Invites); err != nil {
+			return nil, err
+		}
+		code.CreatedAt = parseTime(createdAt)
+		codes = append(codes, code)
+	}
+
+	return codes, rows.Err()
+}
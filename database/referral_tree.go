@@ -0,0 +1,96 @@
+package database
+
+import "telegram-bot/models"
+
+// referralEntriesQuery выбирает прямых рефералов с заработком пригласившего на каждом из них
+const referralEntriesQuery = `
+	SELECT r.referred_id, COALESCE(u.username, ''), r.date_added, r.status,
+		(SELECT COALESCE(SUM(l.amount), 0) FROM ledger l
+			WHERE l.user_id = r.referrer_id AND l.ref_user_id = r.referred_id AND l.kind = 'referral_reward')
+	FROM referrals r
+	LEFT JOIN users u ON u.user_id = r.referred_id
+	WHERE r.referrer_id = ?
+	ORDER BY r.date_added DESC, r.referred_id DESC`
+
+func (d *Database) referralEntries(referrerID int64, limit, offset int) ([]models.ReferralEntry, error) {
+	rows, err := d.db.Query(referralEntriesQuery+` LIMIT ? OFFSET ?`, referrerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.ReferralEntry
+	for rows.Next() {
+		var entry models.ReferralEntry
+		var joinedAt string
+		if err := rows.Scan(&entry.UserID, &entry.Username, &joinedAt, &entry.Status, &entry.Earned); err != nil {
+			return nil, err
+		}
+		entry.JoinedAt = parseTime(joinedAt)
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// ListReferrals возвращает страницу прямых рефералов, начиная с новых, и их общее количество
+func (d *Database) ListReferrals(referrerID int64, limit, offset int) ([]models.ReferralEntry, int, error) {
+	var total int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM referrals WHERE referrer_id = ?`, referrerID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := d.referralEntries(referrerID, limit, offset)
+	return entries, total, err
+}
+
+// Downline строит дерево приглашений пользователя обходом в ширину. Обход останавливается
+// на глубине maxDepth или после maxNodes рефералов; truncated сообщает, что дерево неполное
+func (d *Database) Downline(rootID int64, maxDepth, maxNodes int) (root *models.ReferralNode, nodes int, truncated bool, err error) {
+	user, err := d.GetUser(rootID)
+	if err != nil || user == nil {
+		return nil, 0, false, err
+	}
+
+	root = &models.ReferralNode{ReferralEntry: models.ReferralEntry{
+		UserID:   user.UserID,
+		Username: user.Username,
+		JoinedAt: user.JoinDate,
+	}}
+
+	// Защита от циклов в цепочке, которые могли появиться из-за ручных правок базы
+	visited := map[int64]bool{rootID: true}
+	level := []*models.ReferralNode{root}
+
+	for depth := 1; len(level) > 0; depth++ {
+		var next []*models.ReferralNode
+		for _, parent := range level {
+			entries, err := d.referralEntries(parent.UserID, -1, 0)
+			if err != nil {
+				return nil, 0, false, err
+			}
+			if len(entries) > 0 && depth > maxDepth {
+				truncated = true
+				continue
+			}
+
+			for _, entry := range entries {
+				if visited[entry.UserID] {
+					continue
+				}
+				if nodes == maxNodes {
+					return root, nodes, true, nil
+				}
+				visited[entry.UserID] = true
+				nodes++
+
+				child := &models.ReferralNode{ReferralEntry: entry}
+				parent.Children = append(parent.Children, child)
+				next = append(next, child)
+			}
+		}
+		level = next
+	}
+
+	return root, nodes, truncated, nil
+}
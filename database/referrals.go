@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"telegram-bot/models"
+	"time"
+)
+
+// payReferralRewards поднимается по цепочке referred_by от пользователя userID
+// и начисляет каждому рефереру награду его уровня. Заблокированные и лишенные
+// наград рефереры пропускаются, но цепочка за ними продолжается
+func payReferralRewards(tx *sql.Tx, userID int64, rewards []float64) ([]models.ReferralReward, error) {
+	var paid []models.ReferralReward
+
+	// Защита от циклов в цепочке, которые могли появиться из-за ручных правок базы
+	visited := map[int64]bool{userID: true}
+	current := userID
+
+	for level := 1; level <= len(rewards); level++ {
+		var referredBy sql.NullInt64
+		if err := tx.QueryRow(`SELECT referred_by FROM users WHERE user_id = ?`, current).Scan(&referredBy); err != nil {
+			return nil, err
+		}
+		if !referredBy.Valid || visited[referredBy.Int64] {
+			break
+		}
+		referrerID := referredBy.Int64
+		visited[referrerID] = true
+		current = referrerID
+
+		var banned, noRewards bool
+		err := tx.QueryRow(`SELECT banned, no_rewards FROM users WHERE user_id = ?`, referrerID).Scan(&banned, &noRewards)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		amount := rewards[level-1]
+		if banned || noRewards || amount <= 0 {
+			continue
+		}
+
+		note := fmt.Sprintf("level %d", level)
+		if _, err := addLedgerEntry(tx, referrerID, models.LedgerReferralReward, amount, &userID, nil, note); err != nil {
+			return nil, err
+		}
+
+		paid = append(paid, models.ReferralReward{UserID: referrerID, Level: level, Amount: amount})
+	}
+
+	return paid, nil
+}
+
+// MarkReferralLanguageChosen отмечает, как приглашенный пользователь получил язык
+// (models.LanguagePicked или models.LanguageDetected). Ручной выбор не перезаписывается
+func (d *Database) MarkReferralLanguageChosen(referredID int64, source int) error {
+	_, err := d.db.Exec(`UPDATE referrals SET language_chosen = ? WHERE referred_id = ? AND status = ? AND language_chosen != ?`,
+		source, referredID, models.ReferralPending, models.LanguagePicked)
+	return err
+}
+
+// HasPendingReferral сообщает, ожидает ли пользователь подтверждения как реферал
+func (d *Database) HasPendingReferral(referredID int64) (bool, error) {
+	var exists bool
+	err := d.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM referrals WHERE referred_id = ? AND status = ?)`,
+		referredID, models.ReferralPending).Scan(&exists)
+	return exists, err
+}
+
+// ReferralPolicy - правила подтверждения реферала и начисления наград
+type ReferralPolicy struct {
+	Conditions []string  // models.ReferralCond*, проверяемые в базе
+	ActiveDays int       // для models.ReferralCondActiveDays
+	Rewards    []float64 // суммы наград по уровням цепочки
+	// Screen оценивает реферала перед выплатой; nil - без антифрода
+	Screen func(models.ReferralSignals) models.FraudVerdict
+}
+
+// ConfirmReferral проверяет условия подтверждения неподтвержденного реферала и,
+// если все они выполнены, подтверждает его и начисляет награды по цепочке.
+// Если антифрод признал реферала подозрительным, награда задерживается
+// и возвращается описание задержанного реферала.
+// Возвращает nil, nil, если реферала нет, он уже обработан или условия еще не выполнены
+func (d *Database) ConfirmReferral(referredID int64, policy ReferralPolicy) ([]models.ReferralReward, *models.HeldReferral, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var languageChosen int
+	var banned, captchaPassed bool
+	err = tx.QueryRow(`
+		SELECT r.language_chosen, u.banned, u.captcha_passed
+		FROM referrals r
+		JOIN users u ON u.user_id = r.referred_id
+		WHERE r.referred_id = ? AND r.status = ?`, referredID, models.ReferralPending).Scan(&languageChosen, &banned, &captchaPassed)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	// Пока приглашенный не прошел капчу, награда не начисляется ни при каких условиях
+	if banned || !captchaPassed {
+		return nil, nil, nil
+	}
+
+	for _, condition := range policy.Conditions {
+		switch condition {
+		case models.ReferralCondLanguage:
+			if languageChosen == models.LanguageNotChosen {
+				return nil, nil, nil
+			}
+		case models.ReferralCondActiveDays:
+			var days int
+			err := tx.QueryRow(`SELECT COUNT(*) FROM user_activity_days WHERE user_id = ?`, referredID).Scan(&days)
+			if err != nil {
+				return nil, nil, err
+			}
+			if days < policy.ActiveDays {
+				return nil, nil, nil
+			}
+		default:
+			return nil, nil, fmt.Errorf("unknown referral condition %q", condition)
+		}
+	}
+
+	now := time.Now().Format(timeLayout)
+
+	if policy.Screen != nil {
+		signals, err := referralSignals(tx, referredID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		verdict := policy.Screen(signals)
+		if verdict.Suspicious {
+			result, err := tx.Exec(`UPDATE referrals SET status = ?, fraud_score = ?, fraud_reasons = ?, flagged_at = ?
+				WHERE referred_id = ? AND status = ?`,
+				models.ReferralHeld, verdict.Score, strings.Join(verdict.Reasons, ","), now, referredID, models.ReferralPending)
+			if err != nil {
+				return nil, nil, err
+			}
+			if affected, _ := result.RowsAffected(); affected == 0 {
+				return nil, nil, nil
+			}
+
+			held := &models.HeldReferral{
+				ReferrerID: signals.ReferrerID,
+				ReferredID: referredID,
+				Score:      verdict.Score,
+				Reasons:    verdict.Reasons,
+			}
+			return nil, held, tx.Commit()
+		}
+	}
+
+	result, err := tx.Exec(`UPDATE referrals SET status = ?, confirmed_at = ? WHERE referred_id = ? AND status = ?`,
+		models.ReferralConfirmed, now, referredID, models.ReferralPending)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Параллельная проверка уже подтвердила реферала
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, nil, nil
+	}
+
+	paid, err := payReferralRewards(tx, referredID, policy.Rewards)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return paid, nil, tx.Commit()
+}
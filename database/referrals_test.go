@@ -0,0 +1,154 @@
+package database
+
+import (
+	"telegram-bot/models"
+	"testing"
+)
+
+func TestConfirmReferralPaysLevels(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 0)
+	createUser(t, d, 2, ptr(1), 0)
+	createUser(t, d, 3, ptr(2), 0)
+
+	paid, held, err := d.ConfirmReferral(3, ReferralPolicy{Rewards: []float64{10, 2, 1}})
+	if err != nil || held != nil {
+		t.Fatalf("ConfirmReferral = %v, %v, %v", paid, held, err)
+	}
+
+	want := []models.ReferralReward{{UserID: 2, Level: 1, Amount: 10}, {UserID: 1, Level: 2, Amount: 2}}
+	if len(paid) != len(want) {
+		t.Fatalf("paid = %+v, want %+v", paid, want)
+	}
+	for i := range want {
+		if paid[i] != want[i] {
+			t.Errorf("paid[%d] = %+v, want %+v", i, paid[i], want[i])
+		}
+	}
+	assertBalance(t, d, 2, 10)
+	assertBalance(t, d, 1, 2)
+
+	// Повторное подтверждение ничего не начисляет
+	paid, _, err = d.ConfirmReferral(3, ReferralPolicy{Rewards: []float64{10, 2, 1}})
+	if err != nil || paid != nil {
+		t.Errorf("second ConfirmReferral = %v, %v", paid, err)
+	}
+	assertBalance(t, d, 2, 10)
+}
+
+// Заблокированный реферер пропускается, но следующий уровень получает награду
+func TestConfirmReferralSkipsBannedReferrer(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 0)
+	createUser(t, d, 2, ptr(1), 0)
+	createUser(t, d, 3, ptr(2), 0)
+	if err := d.SetUserRestriction(2, models.RestrictionBanned, true); err != nil {
+		t.Fatal(err)
+	}
+
+	paid, _, err := d.ConfirmReferral(3, ReferralPolicy{Rewards: []float64{10, 2}})
+	if err != nil || len(paid) != 1 || paid[0].UserID != 1 || paid[0].Level != 2 {
+		t.Fatalf("ConfirmReferral = %+v, %v", paid, err)
+	}
+	assertBalance(t, d, 2, 0)
+	assertBalance(t, d, 1, 2)
+}
+
+// Цикл в цепочке, созданный правкой базы, не приводит к повторным выплатам
+func TestConfirmReferralCycle(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 0)
+	createUser(t, d, 2, ptr(1), 0)
+	if _, err := d.db.Exec(`UPDATE users SET referred_by = 2 WHERE user_id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	createUser(t, d, 3, ptr(2), 0)
+
+	paid, _, err := d.ConfirmReferral(3, ReferralPolicy{Rewards: []float64{10, 5, 3, 1}})
+	if err != nil || len(paid) != 2 {
+		t.Fatalf("ConfirmReferral = %+v, %v", paid, err)
+	}
+	assertBalance(t, d, 2, 10)
+	assertBalance(t, d, 1, 5)
+}
+
+func TestConfirmReferralConditions(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 0)
+	createUser(t, d, 2, ptr(1), 0)
+	policy := ReferralPolicy{Conditions: []string{models.ReferralCondLanguage}, Rewards: []float64{10}}
+
+	paid, _, err := d.ConfirmReferral(2, policy)
+	if err != nil || paid != nil {
+		t.Fatalf("ConfirmReferral before language = %v, %v", paid, err)
+	}
+
+	if err := d.MarkReferralLanguageChosen(2, models.LanguageDetected); err != nil {
+		t.Fatal(err)
+	}
+	paid, _, err = d.ConfirmReferral(2, policy)
+	if err != nil || len(paid) != 1 {
+		t.Fatalf("ConfirmReferral after language = %v, %v", paid, err)
+	}
+	assertBalance(t, d, 1, 10)
+}
+
+// Пока приглашенный не прошел капчу, реферал не подтверждается
+func TestConfirmReferralCaptcha(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 0)
+	if err := d.CreateUser(NewUser{UserID: 2, ReferredBy: ptr(1), Language: "en", CaptchaRequired: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	paid, held, err := d.ConfirmReferral(2, ReferralPolicy{Rewards: []float64{10}})
+	if err != nil || paid != nil || held != nil {
+		t.Fatalf("ConfirmReferral = %v, %v, %v", paid, held, err)
+	}
+	if pending, _ := d.HasPendingReferral(2); !pending {
+		t.Error("referral is no longer pending")
+	}
+}
+
+func TestConfirmReferralHeld(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 0)
+	createUser(t, d, 2, ptr(1), 0)
+
+	var signals models.ReferralSignals
+	policy := ReferralPolicy{
+		Rewards: []float64{10},
+		Screen: func(s models.ReferralSignals) models.FraudVerdict {
+			signals = s
+			return models.FraudVerdict{Suspicious: true, Score: 70, Reasons: []string{"velocity"}}
+		},
+	}
+
+	paid, held, err := d.ConfirmReferral(2, policy)
+	if err != nil || paid != nil || held == nil {
+		t.Fatalf("ConfirmReferral = %v, %v, %v", paid, held, err)
+	}
+	if held.ReferrerID != 1 || held.ReferredID != 2 || held.Score != 70 {
+		t.Errorf("held = %+v", held)
+	}
+	if signals.ReferrerID != 1 || signals.Interacted {
+		t.Errorf("signals = %+v", signals)
+	}
+	assertBalance(t, d, 1, 0)
+
+	// Задержанный реферал не подтверждается повторной проверкой
+	if paid, held, _ := d.ConfirmReferral(2, policy); paid != nil || held != nil {
+		t.Errorf("second ConfirmReferral = %v, %v", paid, held)
+	}
+
+	paid, err = d.ReleaseHeldReferral(2, 99, []float64{10})
+	if err != nil || len(paid) != 1 {
+		t.Fatalf("ReleaseHeldReferral = %v, %v", paid, err)
+	}
+	assertBalance(t, d, 1, 10)
+
+	if _, err := d.ReleaseHeldReferral(2, 99, []float64{10}); err != ErrReferralNotHeld {
+		t.Errorf("second ReleaseHeldReferral error = %v, want ErrReferralNotHeld", err)
+	}
+	assertBalance(t, d, 1, 10)
+}
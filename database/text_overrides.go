@@ -0,0 +1,43 @@
+package database
+
+import "time"
+
+// TextOverrides возвращает тексты, измененные администраторами: lang → key → текст
+func (d *Database) TextOverrides() (map[string]map[string]string, error) {
+	rows, err := d.db.Query(`SELECT lang, key, text FROM text_overrides`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]map[string]string)
+	for rows.Next() {
+		var lang, key, text string
+		if err := rows.Scan(&lang, &key, &text); err != nil {
+			return nil, err
+		}
+		if overrides[lang] == nil {
+			overrides[lang] = make(map[string]string)
+		}
+		overrides[lang][key] = text
+	}
+
+	return overrides, rows.Err()
+}
+
+// SetTextOverride сохраняет текст перевода, заменяющий значение из файлов
+func (d *Database) SetTextOverride(lang, key, text string, updatedBy int64) error {
+	_, err := d.db.Exec(`INSERT OR REPLACE INTO text_overrides (lang, key, text, updated_by, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		lang, key, text, updatedBy, time.Now().Format(timeLayout))
+	return err
+}
+
+// DeleteTextOverride удаляет измененный текст; возвращает false, если его не было
+func (d *Database) DeleteTextOverride(lang, key string) (bool, error) {
+	result, err := d.db.Exec(`DELETE FROM text_overrides WHERE lang = ? AND key = ?`, lang, key)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
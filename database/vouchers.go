@@ -0,0 +1,262 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"telegram-bot/models"
+	"time"
+)
+
+const voucherCodeLength = 10
+
+var (
+	ErrVoucherNotFound   = errors.New("voucher not found")
+	ErrVoucherNotActive  = errors.New("voucher is already claimed or expired")
+	ErrVoucherNotForUser = errors.New("voucher is addressed to another user")
+	ErrVoucherOwn        = errors.New("cannot claim own voucher")
+	ErrVoucherBanned     = errors.New("banned users cannot claim vouchers")
+	// Ваучер без получателя достается первому, кто откроет ссылку, поэтому боты
+	// не должны получать его до прохождения капчи
+	ErrVoucherCaptchaRequired = errors.New("voucher can be claimed after the captcha")
+)
+
+const voucherColumns = `code, sender_id, amount, recipient_id, status, claimed_by, claimed_at, created_at, expires_at`
+
+func scanVoucher(row rowScanner) (*models.Voucher, error) {
+	var voucher models.Voucher
+	var recipientID, claimedBy sql.NullInt64
+	var claimedAt sql.NullString
+	var createdAt, expiresAt string
+
+	err := row.Scan(&voucher.Code, &voucher.SenderID, &voucher.Amount, &recipientID, &voucher.Status,
+		&claimedBy, &claimedAt, &createdAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if recipientID.Valid {
+		voucher.RecipientID = &recipientID.Int64
+	}
+	if claimedBy.Valid {
+		voucher.ClaimedBy = &claimedBy.Int64
+	}
+	voucher.ClaimedAt = parseNullTime(claimedAt)
+	voucher.CreatedAt = parseTime(createdAt)
+	voucher.ExpiresAt = parseTime(expiresAt)
+
+	return &voucher, nil
+}
+
+// CreateVoucher блокирует сумму на балансе отправителя и создает ваучер с уникальным кодом
+func (d *Database) CreateVoucher(senderID int64, amount float64, recipientID *int64, ttl time.Duration, limits GiftLimits) (*models.Voucher, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var balance float64
+	var frozen, banned bool
+	err = tx.QueryRow(`SELECT balance, frozen, banned FROM users WHERE user_id = ?`, senderID).Scan(&balance, &frozen, &banned)
+	if err != nil {
+		return nil, err
+	}
+	if frozen || banned {
+		return nil, ErrGiftSenderRestricted
+	}
+	if balance < amount {
+		return nil, ErrInsufficientFunds
+	}
+	if balance-amount < limits.MinBalance {
+		return nil, ErrGiftMinBalance
+	}
+
+	if limits.DailyLimit > 0 {
+		sent, err := giftedToday(tx, senderID)
+		if err != nil {
+			return nil, err
+		}
+		if sent+amount > limits.DailyLimit {
+			return nil, ErrGiftDailyLimit
+		}
+	}
+
+	code, err := generateCode(voucherCodeLength)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	voucher := &models.Voucher{
+		Code:        code,
+		SenderID:    senderID,
+		Amount:      amount,
+		RecipientID: recipientID,
+		Status:      models.VoucherActive,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+
+	_, err = tx.Exec(`INSERT INTO gift_vouchers (code, sender_id, amount, recipient_id, status, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		voucher.Code, senderID, amount, recipientID, voucher.Status,
+		now.Format(timeLayout), voucher.ExpiresAt.Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := addLedgerEntry(tx, senderID, models.LedgerVoucherCreated, -amount, recipientID, nil, code); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return voucher, nil
+}
+
+// ClaimVoucher зачисляет сумму ваучера пользователю; ваучер можно получить только один раз
+func (d *Database) ClaimVoucher(code string, userID int64) (*models.Voucher, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	voucher, err := scanVoucher(tx.QueryRow(`SELECT `+voucherColumns+` FROM gift_vouchers WHERE code = ?`, code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrVoucherNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	switch {
+	case voucher.Status != models.VoucherActive || !now.Before(voucher.ExpiresAt):
+		return voucher, ErrVoucherNotActive
+	case voucher.SenderID == userID:
+		return voucher, ErrVoucherOwn
+	case voucher.RecipientID != nil && *voucher.RecipientID != userID:
+		return voucher, ErrVoucherNotForUser
+	}
+
+	var banned, captchaPassed bool
+	err = tx.QueryRow(`SELECT banned, captcha_passed FROM users WHERE user_id = ?`, userID).Scan(&banned, &captchaPassed)
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return voucher, ErrVoucherBanned
+	}
+	if !captchaPassed {
+		return voucher, ErrVoucherCaptchaRequired
+	}
+
+	result, err := tx.Exec(`UPDATE gift_vouchers SET status = ?, claimed_by = ?, claimed_at = ? WHERE code = ? AND status = ?`,
+		models.VoucherClaimed, userID, now.Format(timeLayout), code, models.VoucherActive)
+	if err != nil {
+		return nil, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return voucher, ErrVoucherNotActive
+	}
+
+	if _, err := addLedgerEntry(tx, userID, models.LedgerVoucherClaimed, voucher.Amount, &voucher.SenderID, nil, code); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	voucher.Status = models.VoucherClaimed
+	voucher.ClaimedBy = &userID
+	voucher.ClaimedAt = &now
+	return voucher, nil
+}
+
+// SetPendingVoucher запоминает ваучер, который пользователь получит после прохождения капчи
+func (d *Database) SetPendingVoucher(userID int64, code string) error {
+	_, err := d.db.Exec(`UPDATE users SET pending_voucher = ? WHERE user_id = ?`, code, userID)
+	return err
+}
+
+// TakePendingVoucher возвращает и забывает отложенный ваучер пользователя ("" - его нет)
+func (d *Database) TakePendingVoucher(userID int64) (string, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var code sql.NullString
+	err = tx.QueryRow(`SELECT pending_voucher FROM users WHERE user_id = ?`, userID).Scan(&code)
+	if err == sql.ErrNoRows || !code.Valid {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tx.Exec(`UPDATE users SET pending_voucher = NULL WHERE user_id = ?`, userID); err != nil {
+		return "", err
+	}
+	return code.String, tx.Commit()
+}
+
+// ExpireVouchers возвращает отправителям суммы просроченных ваучеров
+func (d *Database) ExpireVouchers() ([]models.Voucher, error) {
+	rows, err := d.db.Query(`SELECT `+voucherColumns+` FROM gift_vouchers WHERE status = ? AND expires_at <= ?`,
+		models.VoucherActive, time.Now().Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []models.Voucher
+	for rows.Next() {
+		if voucher, err := scanVoucher(rows); err == nil {
+			candidates = append(candidates, *voucher)
+		}
+	}
+	rows.Close()
+
+	var expired []models.Voucher
+	for _, voucher := range candidates {
+		refunded, err := d.refundVoucher(voucher)
+		if err != nil {
+			return expired, err
+		}
+		if refunded {
+			voucher.Status = models.VoucherExpired
+			expired = append(expired, voucher)
+		}
+	}
+
+	return expired, nil
+}
+
+func (d *Database) refundVoucher(voucher models.Voucher) (bool, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Ваучер могли получить между выборкой и возвратом
+	result, err := tx.Exec(`UPDATE gift_vouchers SET status = ? WHERE code = ? AND status = ?`,
+		models.VoucherExpired, voucher.Code, models.VoucherActive)
+	if err != nil {
+		return false, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+
+	if _, err := addLedgerEntry(tx, voucher.SenderID, models.LedgerVoucherRefund, voucher.Amount, nil, nil, voucher.Code); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
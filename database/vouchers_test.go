@@ -0,0 +1,95 @@
+package database
+
+import (
+	"telegram-bot/models"
+	"testing"
+	"time"
+)
+
+func TestClaimVoucherOnce(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 100)
+	createUser(t, d, 2, nil, 0)
+	createUser(t, d, 3, nil, 0)
+
+	voucher, err := d.CreateVoucher(1, 40, nil, time.Hour, GiftLimits{})
+	if err != nil {
+		t.Fatalf("CreateVoucher: %v", err)
+	}
+	assertBalance(t, d, 1, 60)
+
+	if _, err := d.ClaimVoucher(voucher.Code, 1); err != ErrVoucherOwn {
+		t.Errorf("claim by sender error = %v, want ErrVoucherOwn", err)
+	}
+
+	claimed, err := d.ClaimVoucher(voucher.Code, 2)
+	if err != nil || claimed.Status != models.VoucherClaimed {
+		t.Fatalf("ClaimVoucher = %+v, %v", claimed, err)
+	}
+	assertBalance(t, d, 2, 40)
+
+	// Второй раз ваучер не выплачивается ни тому же, ни другому пользователю
+	if _, err := d.ClaimVoucher(voucher.Code, 2); err != ErrVoucherNotActive {
+		t.Errorf("second claim error = %v, want ErrVoucherNotActive", err)
+	}
+	if _, err := d.ClaimVoucher(voucher.Code, 3); err != ErrVoucherNotActive {
+		t.Errorf("claim by another user error = %v, want ErrVoucherNotActive", err)
+	}
+	assertBalance(t, d, 2, 40)
+	assertBalance(t, d, 3, 0)
+}
+
+func TestClaimVoucherRestrictions(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 100)
+	createUser(t, d, 2, nil, 0)
+	createUser(t, d, 3, nil, 0)
+	if err := d.CreateUser(NewUser{UserID: 4, Language: "en", CaptchaRequired: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SetUserRestriction(3, models.RestrictionBanned, true); err != nil {
+		t.Fatal(err)
+	}
+
+	addressed, err := d.CreateVoucher(1, 10, ptr(2), time.Hour, GiftLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ClaimVoucher(addressed.Code, 3); err != ErrVoucherNotForUser {
+		t.Errorf("claim of addressed voucher error = %v, want ErrVoucherNotForUser", err)
+	}
+
+	open, err := d.CreateVoucher(1, 10, nil, time.Hour, GiftLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ClaimVoucher(open.Code, 3); err != ErrVoucherBanned {
+		t.Errorf("claim by banned user error = %v, want ErrVoucherBanned", err)
+	}
+	if _, err := d.ClaimVoucher(open.Code, 4); err != ErrVoucherCaptchaRequired {
+		t.Errorf("claim before captcha error = %v, want ErrVoucherCaptchaRequired", err)
+	}
+	if _, err := d.ClaimVoucher("missing", 2); err != ErrVoucherNotFound {
+		t.Errorf("claim of unknown code error = %v, want ErrVoucherNotFound", err)
+	}
+
+	// Отклоненные попытки не расходуют ваучер
+	if _, err := d.ClaimVoucher(open.Code, 2); err != nil {
+		t.Errorf("ClaimVoucher: %v", err)
+	}
+	assertBalance(t, d, 3, 0)
+	assertBalance(t, d, 2, 10)
+}
+
+func TestCreateVoucherFrozenSender(t *testing.T) {
+	d := newTestDB(t)
+	createUser(t, d, 1, nil, 100)
+	if err := d.SetUserRestriction(1, models.RestrictionFrozen, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.CreateVoucher(1, 10, nil, time.Hour, GiftLimits{}); err != ErrGiftSenderRestricted {
+		t.Fatalf("CreateVoucher error = %v, want ErrGiftSenderRestricted", err)
+	}
+	assertBalance(t, d, 1, 100)
+}
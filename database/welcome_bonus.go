@@ -0,0 +1,124 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"telegram-bot/models"
+	"time"
+)
+
+// GetWelcomeBonus возвращает приветственный бонус пользователя или nil, если его нет
+func (d *Database) GetWelcomeBonus(userID int64) (*models.WelcomeBonus, error) {
+	bonus := models.WelcomeBonus{UserID: userID}
+	var createdAt string
+	var unlockedAt sql.NullString
+
+	err := d.db.QueryRow(`SELECT amount, source, created_at, unlocked_at FROM welcome_bonuses WHERE user_id = ?`, userID).
+		Scan(&bonus.Amount, &bonus.Source, &createdAt, &unlockedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	bonus.CreatedAt = parseTime(createdAt)
+	bonus.UnlockedAt = parseNullTime(unlockedAt)
+
+	return &bonus, nil
+}
+
+// UnmetBonusConditions возвращает условия разблокировки, которые пользователь еще не выполнил
+func (d *Database) UnmetBonusConditions(userID int64, conditions []string) ([]string, error) {
+	return unmetBonusConditions(d.db, userID, conditions)
+}
+
+func unmetBonusConditions(q queryRower, userID int64, conditions []string) ([]string, error) {
+	var unmet []string
+	for _, condition := range conditions {
+		var met bool
+		var err error
+
+		switch condition {
+		case models.BonusUnlockOnboarding:
+			err = q.QueryRow(`SELECT onboarded_at IS NOT NULL FROM users WHERE user_id = ?`, userID).Scan(&met)
+		case models.BonusUnlockInvite:
+			err = q.QueryRow(`SELECT EXISTS (SELECT 1 FROM referrals WHERE referrer_id = ? AND status = ?)`,
+				userID, models.ReferralConfirmed).Scan(&met)
+		default:
+			return nil, fmt.Errorf("unknown welcome bonus condition %q", condition)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !met {
+			unmet = append(unmet, condition)
+		}
+	}
+
+	return unmet, nil
+}
+
+// UnlockWelcomeBonus зачисляет заблокированный бонус на баланс, если выполнены все условия.
+// Возвращает разблокированный бонус или nil, если разблокировать нечего
+func (d *Database) UnlockWelcomeBonus(userID int64, conditions []string) (*models.WelcomeBonus, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	bonus := models.WelcomeBonus{UserID: userID}
+	var banned bool
+	err = tx.QueryRow(`
+		SELECT b.amount, b.source, u.banned
+		FROM welcome_bonuses b
+		JOIN users u ON u.user_id = b.user_id
+		WHERE b.user_id = ? AND b.unlocked_at IS NULL`, userID).Scan(&bonus.Amount, &bonus.Source, &banned)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if banned {
+		return nil, nil
+	}
+
+	// Бонус за приглашение выдается только после подтверждения реферала: до него
+	// реферал не прошел антифрод, а задержанный или отклоненный не должен получить бонус
+	if bonus.Source == models.BonusSourceReferral {
+		var status string
+		err := tx.QueryRow(`SELECT status FROM referrals WHERE referred_id = ?`, userID).Scan(&status)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if status != models.ReferralConfirmed {
+			return nil, nil
+		}
+	}
+
+	unmet, err := unmetBonusConditions(tx, userID, conditions)
+	if err != nil || len(unmet) > 0 {
+		return nil, err
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`UPDATE welcome_bonuses SET unlocked_at = ? WHERE user_id = ? AND unlocked_at IS NULL`,
+		now.Format(timeLayout), userID)
+	if err != nil {
+		return nil, err
+	}
+	// Бонус уже разблокирован параллельным вызовом
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil, nil
+	}
+
+	if _, err := addLedgerEntry(tx, userID, models.LedgerWelcomeBonus, bonus.Amount, nil, nil, bonus.Source); err != nil {
+		return nil, err
+	}
+
+	bonus.UnlockedAt = &now
+	return &bonus, tx.Commit()
+}
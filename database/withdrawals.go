@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"telegram-bot/models"
+	"time"
+)
+
+var (
+	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrWithdrawalNotPending = errors.New("withdrawal is already processed")
+	// Вывод заморозили или пользователя заблокировали, пока он вводил кошелек
+	ErrWithdrawalRestricted = errors.New("withdrawals are frozen for the user")
+)
+
+// CreateWithdrawal списывает сумму с баланса и создает заявку на вывод
+func (d *Database) CreateWithdrawal(userID int64, amount float64, wallet string) (*models.Withdrawal, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var balance float64
+	var frozen, banned bool
+	err = tx.QueryRow(`SELECT balance, frozen, banned FROM users WHERE user_id = ?`, userID).Scan(&balance, &frozen, &banned)
+	if err != nil {
+		return nil, err
+	}
+	if frozen || banned {
+		return nil, ErrWithdrawalRestricted
+	}
+	if balance < amount {
+		return nil, ErrInsufficientFunds
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`INSERT INTO withdrawals (user_id, amount, wallet, status, created_at) VALUES (?, ?, ?, ?, ?)`,
+		userID, amount, wallet, models.WithdrawalPending, now.Format(timeLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := addLedgerEntry(tx, userID, models.LedgerWithdrawal, -amount, nil, nil, wallet); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &models.Withdrawal{
+		ID:        id,
+		UserID:    userID,
+		Amount:    amount,
+		Wallet:    wallet,
+		Status:    models.WithdrawalPending,
+		CreatedAt: now,
+	}, nil
+}
+
+// GetWithdrawals возвращает последние заявки пользователя, новые первыми
+func (d *Database) GetWithdrawals(userID int64, limit int) ([]models.Withdrawal, error) {
+	rows, err := d.db.Query(`SELECT id, user_id, amount, wallet, status, created_at
+		FROM withdrawals WHERE user_id = ? ORDER BY id DESC LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var withdrawals []models.Withdrawal
+	for rows.Next() {
+		var withdrawal models.Withdrawal
+		var createdAt string
+
+		err := rows.Scan(&withdrawal.ID, &withdrawal.UserID, &withdrawal.Amount, &withdrawal.Wallet,
+			&withdrawal.Status, &createdAt)
+		if err != nil {
+			continue
+		}
+
+		withdrawal.CreatedAt = parseTime(createdAt)
+		withdrawals = append(withdrawals, withdrawal)
+	}
+
+	return withdrawals, nil
+}
+
+func (d *Database) GetWithdrawal(id int64) (*models.Withdrawal, error) {
+	var withdrawal models.Withdrawal
+	var createdAt string
+
+	err := d.db.QueryRow(`SELECT id, user_id, amount, wallet, status, created_at FROM withdrawals WHERE id = ?`, id).
+		Scan(&withdrawal.ID, &withdrawal.UserID, &withdrawal.Amount, &withdrawal.Wallet, &withdrawal.Status, &createdAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	withdrawal.CreatedAt = parseTime(createdAt)
+	return &withdrawal, nil
+}
+
+// ApproveWithdrawal отмечает заявку выполненной
+func (d *Database) ApproveWithdrawal(id, actorID int64) error {
+	result, err := d.db.Exec(`UPDATE withdrawals SET status = ?, processed_by = ?, processed_at = ? WHERE id = ? AND status = ?`,
+		models.WithdrawalApproved, actorID, time.Now().Format(timeLayout), id, models.WithdrawalPending)
+	if err != nil {
+		return err
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrWithdrawalNotPending
+	}
+	return nil
+}
+
+// RejectWithdrawal отклоняет заявку и возвращает сумму на баланс пользователя
+func (d *Database) RejectWithdrawal(id, actorID int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	var amount float64
+	err = tx.QueryRow(`SELECT user_id, amount FROM withdrawals WHERE id = ? AND status = ?`, id, models.WithdrawalPending).
+		Scan(&userID, &amount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrWithdrawalNotPending
+		}
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE withdrawals SET status = ?, processed_by = ?, processed_at = ? WHERE id = ?`,
+		models.WithdrawalRejected, actorID, time.Now().Format(timeLayout), id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := addLedgerEntry(tx, userID, models.LedgerWithdrawRefund, amount, nil, &actorID, fmt.Sprintf("withdrawal #%d", id)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
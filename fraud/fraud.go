@@ -0,0 +1,83 @@
+package fraud
+
+import "telegram-bot/models"
+
+// Rule - одно правило антифрода: если Check срабатывает, к оценке добавляется Weight
+type Rule struct {
+	Name   string
+	Weight int
+	Check  func(s models.ReferralSignals) bool
+}
+
+// Thresholds - пороги срабатывания стандартных правил, 0 отключает правило
+type Thresholds struct {
+	VelocityPerHour int `yaml:"velocity_per_hour"` // рефералов у одного реферера за час
+	NearbyIDs       int `yaml:"nearby_ids"`        // рефералов с близкими Telegram ID
+	FreshChainDepth int `yaml:"fresh_chain"`       // недавно зарегистрированных рефереров подряд в цепочке
+}
+
+// DefaultRules возвращает стандартный набор правил
+func DefaultRules(t Thresholds) []Rule {
+	return []Rule{
+		{
+			Name:   "velocity",
+			Weight: 40,
+			Check: func(s models.ReferralSignals) bool {
+				return t.VelocityPerHour > 0 && s.RecentReferrals >= t.VelocityPerHour
+			},
+		},
+		{
+			Name:   "id_proximity",
+			Weight: 30,
+			Check: func(s models.ReferralSignals) bool {
+				return t.NearbyIDs > 0 && s.NearbyIDs >= t.NearbyIDs
+			},
+		},
+		{
+			Name:   "no_interaction",
+			Weight: 30,
+			Check: func(s models.ReferralSignals) bool {
+				return !s.Interacted
+			},
+		},
+		{
+			Name:   "fresh_chain",
+			Weight: 40,
+			Check: func(s models.ReferralSignals) bool {
+				return t.FreshChainDepth > 0 && s.FreshChainDepth >= t.FreshChainDepth
+			},
+		},
+		{
+			Name:   "cycle",
+			Weight: 100,
+			Check: func(s models.ReferralSignals) bool {
+				return s.Cycle
+			},
+		},
+	}
+}
+
+// Engine суммирует веса сработавших правил и сравнивает оценку с порогом
+type Engine struct {
+	rules     []Rule
+	threshold int
+}
+
+// NewEngine создает движок. Порог 0 отключает задержку наград
+func NewEngine(rules []Rule, threshold int) *Engine {
+	return &Engine{rules: rules, threshold: threshold}
+}
+
+// Evaluate оценивает реферала
+func (e *Engine) Evaluate(s models.ReferralSignals) models.FraudVerdict {
+	var verdict models.FraudVerdict
+	for _, rule := range e.rules {
+		if rule.Check(s) {
+			verdict.Score += rule.Weight
+			verdict.Reasons = append(verdict.Reasons, rule.Name)
+		}
+	}
+
+	verdict.Suspicious = e.threshold > 0 && verdict.Score >= e.threshold
+	return verdict
+}
@@ -0,0 +1,56 @@
+package fraud
+
+import (
+	"reflect"
+	"telegram-bot/models"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	engine := NewEngine(DefaultRules(Thresholds{VelocityPerHour: 10, NearbyIDs: 3, FreshChainDepth: 3}), 70)
+
+	tests := []struct {
+		name       string
+		signals    models.ReferralSignals
+		score      int
+		reasons    []string
+		suspicious bool
+	}{
+		{"clean", models.ReferralSignals{Interacted: true, RecentReferrals: 2}, 0, nil, false},
+		{"no interaction", models.ReferralSignals{}, 30, []string{"no_interaction"}, false},
+		{
+			"velocity and proximity",
+			models.ReferralSignals{Interacted: true, RecentReferrals: 10, NearbyIDs: 3},
+			70, []string{"velocity", "id_proximity"}, true,
+		},
+		{"cycle", models.ReferralSignals{Interacted: true, Cycle: true}, 100, []string{"cycle"}, true},
+		{
+			"fresh chain",
+			models.ReferralSignals{FreshChainDepth: 5},
+			70, []string{"no_interaction", "fresh_chain"}, true,
+		},
+	}
+
+	for _, tt := range tests {
+		verdict := engine.Evaluate(tt.signals)
+		if verdict.Score != tt.score || verdict.Suspicious != tt.suspicious || !reflect.DeepEqual(verdict.Reasons, tt.reasons) {
+			t.Errorf("%s: Evaluate = %+v, want score %d, reasons %v, suspicious %v",
+				tt.name, verdict, tt.score, tt.reasons, tt.suspicious)
+		}
+	}
+}
+
+// Нулевой порог правила отключает его, нулевой порог движка - задержку наград
+func TestZeroThresholds(t *testing.T) {
+	signals := models.ReferralSignals{Interacted: true, RecentReferrals: 100, NearbyIDs: 100, FreshChainDepth: 100}
+
+	verdict := NewEngine(DefaultRules(Thresholds{}), 70).Evaluate(signals)
+	if verdict.Score != 0 || verdict.Suspicious {
+		t.Errorf("Evaluate with disabled rules = %+v", verdict)
+	}
+
+	verdict = NewEngine(DefaultRules(Thresholds{VelocityPerHour: 1}), 0).Evaluate(models.ReferralSignals{Cycle: true, RecentReferrals: 5})
+	if verdict.Score == 0 || verdict.Suspicious {
+		t.Errorf("Evaluate with threshold 0 = %+v, want a score without a hold", verdict)
+	}
+}
@@ -3,8 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"log"
+	"math"
 	"strconv"
+	"strings"
 	"telegram-bot/config"
 	"telegram-bot/database"
 	"telegram-bot/localization"
@@ -20,6 +23,8 @@ type AdminHandler struct {
 	config   *config.Config
 	loc      *localization.Localization
 	sessions map[int64]*models.UserSession
+	// Последний фильтр /audit каждого администратора для пагинации и экспорта
+	auditFilters map[int64]models.AuditFilter
 }
 
 func NewAdminHandler(bot *tgbotapi.BotAPI, db *database.Database, cfg *config.Config, loc *localization.Localization) *AdminHandler {
@@ -29,13 +34,16 @@ func NewAdminHandler(bot *tgbotapi.BotAPI, db *database.Database, cfg *config.Co
 		config:   cfg,
 		loc:      loc,
 		sessions: make(map[int64]*models.UserSession),
+
+		auditFilters: make(map[int64]models.AuditFilter),
 	}
 }
 
 func (h *AdminHandler) HandleAdminCommand(update tgbotapi.Update) {
 	userID := update.Message.From.ID
 
-	if !h.config.IsAdmin(userID) {
+	admin := h.getAdmin(userID)
+	if admin == nil {
 		user, _ := h.db.GetUser(userID)
 		lang := "ru"
 		if user != nil {
@@ -44,53 +52,73 @@ func (h *AdminHandler) HandleAdminCommand(update tgbotapi.Update) {
 		text := h.loc.Get(lang, "not_admin")
 		msg := tgbotapi.NewMessage(userID, text)
 		h.bot.Send(msg)
+
+		h.audit(userID, models.AuditAccessDenied, nil, map[string]interface{}{"command": "admin"})
 		return
 	}
 
-	h.sendAdminMenu(userID)
+	h.audit(userID, models.AuditOpenMenu, nil, nil)
+	h.sendAdminMenu(admin)
 }
 
-func (h *AdminHandler) sendAdminMenu(userID int64) {
-	user, _ := h.db.GetUser(userID)
-	lang := "ru"
-	if user != nil {
-		lang = user.Language
-	}
+// adminMenuButtons - кнопки админ-меню в порядке отображения, по две в ряд
+var adminMenuButtons = []struct {
+	key, callback string
+}{
+	{"btn_user_count", "admin_user_count"},
+	{"btn_stats", "admin_stats"},
+	{"btn_campaigns", "admin_campaigns"},
+	{"btn_db_download", "admin_db_download"},
+	{"btn_mass_message", "admin_mass_message"},
+	{"btn_change_balance", "admin_change_balance"},
+	{"btn_user_search", "admin_user_search"},
+	{"btn_audit", "admin_audit"},
+	{"btn_admins", "admin_admins"},
+}
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_user_count"), "admin_user_count"),
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_stats"), "admin_stats"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_db_download"), "admin_db_download"),
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_mass_message"), "admin_mass_message"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_change_balance"), "admin_change_balance"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_back_to_user_menu"), "main_menu"),
-		),
-	)
+func (h *AdminHandler) sendAdminMenu(admin *models.Admin) {
+	lang := h.adminLanguage(admin.UserID)
 
-	text := h.loc.Get(lang, "admin_activated")
-	msg := tgbotapi.NewMessage(userID, text)
-	msg.ReplyMarkup = keyboard
+	// Показываем только разделы, доступные роли администратора
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, button := range adminMenuButtons {
+		if !admin.Role.Has(callbackPermission(button.callback)) {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, button.key), button.callback))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_back_to_user_menu"), "main_menu"),
+	))
+
+	text := h.loc.Get(lang, "admin_activated") + "\n" + h.loc.Get(lang, "admin_role_line", localization.Args{"Role": h.roleName(lang, admin.Role.Name)})
+	msg := tgbotapi.NewMessage(admin.UserID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
 	h.bot.Send(msg)
 }
 
 func (h *AdminHandler) HandleAdminCallback(query *tgbotapi.CallbackQuery) {
 	userID := query.From.ID
 
-	if !h.config.IsAdmin(userID) {
+	admin := h.getAdmin(userID)
+	if admin == nil {
 		return
 	}
 
-	user, _ := h.db.GetUser(userID)
-	lang := "ru"
-	if user != nil {
-		lang = user.Language
+	lang := h.adminLanguage(userID)
+
+	if !admin.Role.Has(callbackPermission(query.Data)) {
+		callback := tgbotapi.NewCallbackWithAlert(query.ID, h.loc.Get(lang, "no_permission"))
+		h.bot.Request(callback)
+		return
 	}
 
 	switch query.Data {
@@ -98,12 +126,34 @@ func (h *AdminHandler) HandleAdminCallback(query *tgbotapi.CallbackQuery) {
 		h.handleUserCount(query, lang)
 	case "admin_stats":
 		h.handleStats(query, lang)
+	case "admin_campaigns":
+		h.handleCampaignStats(query, lang)
 	case "admin_db_download":
 		h.handleDBDownload(query, lang)
 	case "admin_mass_message":
 		h.handleMassMessageStart(query, lang)
 	case "admin_change_balance":
 		h.handleChangeBalanceStart(query, lang)
+	case "admin_user_search":
+		h.startUserSearch(query.From.ID, lang)
+	case "admin_balance_confirm":
+		h.handleBalanceConfirm(query, lang, true)
+	case "admin_balance_cancel":
+		h.handleBalanceConfirm(query, lang, false)
+	default:
+		if strings.HasPrefix(query.Data, "admin_card_") {
+			h.handleCardAction(query, lang)
+		} else if strings.HasPrefix(query.Data, "admin_audit") {
+			h.handleAuditCallback(query, lang)
+		} else if strings.HasPrefix(query.Data, "admin_wd_") {
+			h.handleWithdrawalDecision(query, lang)
+		} else if strings.HasPrefix(query.Data, "admin_fraud_") {
+			h.handleFraudDecision(query, lang)
+		} else if strings.HasPrefix(query.Data, "admin_text_") {
+			h.handleTextCallback(query, lang)
+		} else if strings.HasPrefix(query.Data, "admin_adm") || strings.HasPrefix(query.Data, "admin_role") {
+			h.handleAdminsCallback(query, lang)
+		}
 	}
 
 	callback := tgbotapi.NewCallback(query.ID, "")
@@ -117,8 +167,9 @@ func (h *AdminHandler) handleUserCount(query *tgbotapi.CallbackQuery, lang strin
 		return
 	}
 
-	text := fmt.Sprintf("%s\nВсего пользователей: %d",
-		h.loc.Get(lang, "btn_user_count"), stats.Total)
+	h.audit(query.From.ID, models.AuditViewUserCount, nil, nil)
+
+	text := h.loc.Get(lang, "user_count_text", localization.Args{"Total": stats.Total})
 
 	msg := tgbotapi.NewMessage(query.From.ID, text)
 	h.bot.Send(msg)
@@ -131,8 +182,17 @@ func (h *AdminHandler) handleStats(query *tgbotapi.CallbackQuery, lang string) {
 		return
 	}
 
+	h.audit(query.From.ID, models.AuditViewStats, nil, nil)
+
 	title := h.loc.Get(lang, "stats_title")
-	text := h.loc.Get(lang, "stats_text", stats.Total, stats.Day, stats.Week, stats.Month)
+	text := h.loc.Get(lang, "stats_text", localization.Args{
+		"Total":   stats.Total,
+		"Active":  stats.Active,
+		"Blocked": stats.Blocked,
+		"Day":     stats.Day,
+		"Week":    stats.Week,
+		"Month":   stats.Month,
+	})
 
 	fullText := fmt.Sprintf("<b>%s</b>\n\n%s", title, text)
 	msg := tgbotapi.NewMessage(query.From.ID, fullText)
@@ -148,7 +208,7 @@ func (h *AdminHandler) handleDBDownload(query *tgbotapi.CallbackQuery, lang stri
 	}
 
 	if len(users) == 0 {
-		text := "База данных пуста."
+		text := h.loc.Get(lang, "db_empty")
 		msg := tgbotapi.NewMessage(query.From.ID, text)
 		h.bot.Send(msg)
 		return
@@ -168,6 +228,8 @@ func (h *AdminHandler) handleDBDownload(query *tgbotapi.CallbackQuery, lang stri
 		Bytes: jsonData,
 	}
 
+	h.audit(query.From.ID, models.AuditDBDownload, nil, map[string]interface{}{"users": len(users)})
+
 	doc := tgbotapi.NewDocument(query.From.ID, fileBytes)
 	doc.Caption = h.loc.Get(lang, "db_caption")
 	h.bot.Send(doc)
@@ -196,7 +258,7 @@ func (h *AdminHandler) handleChangeBalanceStart(query *tgbotapi.CallbackQuery, l
 func (h *AdminHandler) HandleMessage(update tgbotapi.Update) {
 	userID := update.Message.From.ID
 
-	if !h.config.IsAdmin(userID) {
+	if !h.isAdmin(userID) {
 		return
 	}
 
@@ -217,6 +279,13 @@ func (h *AdminHandler) HandleMessage(update tgbotapi.Update) {
 		lang = user.Language
 	}
 
+	// Право могли отозвать, пока диалог был открыт
+	if !h.can(userID, statePermission(session.State)) {
+		delete(h.sessions, userID)
+		h.sendNoPermission(userID, lang)
+		return
+	}
+
 	switch session.State {
 	case "awaiting_broadcast_message":
 		h.handleBroadcastMessage(update.Message, lang)
@@ -224,47 +293,78 @@ func (h *AdminHandler) HandleMessage(update tgbotapi.Update) {
 		h.handleBalanceUserID(update.Message, lang, session)
 	case "awaiting_balance_amount":
 		h.handleBalanceAmount(update.Message, lang, session)
+	case "awaiting_balance_reason":
+		h.handleBalanceReason(update.Message, lang, session)
+	case "awaiting_user_search":
+		h.handleUserSearch(update.Message, lang)
+	case "awaiting_direct_message":
+		h.handleDirectMessage(update.Message, lang, session)
+	case "awaiting_admin_id":
+		h.handleAdminID(update.Message, lang)
+	case "awaiting_text_override":
+		h.handleTextOverride(update.Message, lang, session)
 	}
 }
 
 func (h *AdminHandler) handleBroadcastMessage(message *tgbotapi.Message, lang string) {
-	userIDs, err := h.db.GetAllUserIDs()
+	userIDs, err := h.db.GetActiveUserIDs()
 	if err != nil {
 		log.Printf("Error getting user IDs: %v", err)
 		return
 	}
 
 	// Уведомляем о начале рассылки
-	text := h.loc.Get(lang, "broadcast_sending", len(userIDs))
+	text := h.loc.Get(lang, "broadcast_sending", localization.Args{"Count": len(userIDs)})
 	msg := tgbotapi.NewMessage(message.From.ID, text)
 	h.bot.Send(msg)
 
 	successCount := 0
 	failCount := 0
+	blockedCount := 0
 
 	// Рассылаем сообщение
 	for _, userID := range userIDs {
-		copyMsg := tgbotapi.NewMessage(userID, message.Text)
+		var err error
 		if message.Photo != nil && len(message.Photo) > 0 {
 			// Если это фото
 			photo := tgbotapi.NewPhoto(userID, tgbotapi.FileID(message.Photo[len(message.Photo)-1].FileID))
 			photo.Caption = message.Caption
-			if _, err := h.bot.Send(photo); err != nil {
-				failCount++
-				continue
-			}
+			_, err = h.bot.Send(photo)
 		} else {
 			// Обычное текстовое сообщение
-			if _, err := h.bot.Send(copyMsg); err != nil {
-				failCount++
+			_, err = h.bot.Send(tgbotapi.NewMessage(userID, message.Text))
+		}
+
+		if err != nil {
+			// Пользователь заблокировал бота - исключаем его из следующих рассылок
+			if isBlockedError(err) {
+				if err := h.db.SetUserActive(userID, false); err != nil {
+					log.Printf("Error marking user %d inactive: %v", userID, err)
+				}
+				blockedCount++
 				continue
 			}
+			failCount++
+			continue
 		}
 		successCount++
 	}
 
+	content := message.Text
+	if content == "" {
+		content = message.Caption
+	}
+	h.audit(message.From.ID, models.AuditBroadcast, nil, map[string]interface{}{
+		"text":    truncate(content, 500),
+		"photo":   len(message.Photo) > 0,
+		"sent":    successCount,
+		"failed":  failCount,
+		"blocked": blockedCount,
+	})
+
 	// Отчет о рассылке
-	reportText := h.loc.Get(lang, "broadcast_complete", successCount, failCount)
+	reportText := h.loc.Get(lang, "broadcast_complete",
+		localization.Args{"Sent": successCount, "Failed": failCount, "Blocked": blockedCount})
 	reportMsg := tgbotapi.NewMessage(message.From.ID, reportText)
 	h.bot.Send(reportMsg)
 
@@ -275,7 +375,7 @@ func (h *AdminHandler) handleBroadcastMessage(message *tgbotapi.Message, lang st
 func (h *AdminHandler) handleBalanceUserID(message *tgbotapi.Message, lang string, session *models.UserSession) {
 	userID, err := strconv.ParseInt(message.Text, 10, 64)
 	if err != nil {
-		text := h.loc.Get(lang, "balance_user_not_found", message.Text)
+		text := h.loc.Get(lang, "balance_user_not_found", localization.Args{"User": message.Text})
 		msg := tgbotapi.NewMessage(message.From.ID, text)
 		h.bot.Send(msg)
 		return
@@ -284,7 +384,7 @@ func (h *AdminHandler) handleBalanceUserID(message *tgbotapi.Message, lang strin
 	// Проверяем, существует ли пользователь
 	targetUser, err := h.db.GetUser(userID)
 	if err != nil || targetUser == nil {
-		text := h.loc.Get(lang, "balance_user_not_found", userID)
+		text := h.loc.Get(lang, "balance_user_not_found", localization.Args{"User": userID})
 		msg := tgbotapi.NewMessage(message.From.ID, text)
 		h.bot.Send(msg)
 		return
@@ -294,34 +394,141 @@ func (h *AdminHandler) handleBalanceUserID(message *tgbotapi.Message, lang strin
 	session.AwaitingBalanceUserID = userID
 	session.State = "awaiting_balance_amount"
 
-	text := h.loc.Get(lang, "balance_prompt_amount", userID, targetUser.Balance)
+	text := h.loc.Get(lang, "balance_prompt_amount", localization.Args{"UserID": userID, "Balance": targetUser.Balance})
 	msg := tgbotapi.NewMessage(message.From.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
 	h.bot.Send(msg)
 }
 
 func (h *AdminHandler) handleBalanceAmount(message *tgbotapi.Message, lang string, session *models.UserSession) {
-	amount, err := strconv.ParseFloat(message.Text, 64)
-	if err != nil {
+	op, value, ok := parseBalanceChange(message.Text)
+	if !ok {
 		text := h.loc.Get(lang, "balance_invalid_amount")
 		msg := tgbotapi.NewMessage(message.From.ID, text)
 		h.bot.Send(msg)
 		return
 	}
 
-	userID := session.AwaitingBalanceUserID
+	// Запоминаем изменение и запрашиваем обязательную причину
+	session.BalanceOp = op
+	session.BalanceValue = value
+	session.State = "awaiting_balance_reason"
 
-	// Обновляем баланс
-	if err := h.db.UpdateUserBalance(userID, amount); err != nil {
-		log.Printf("Error updating balance for user %d: %v", userID, err)
+	text := h.loc.Get(lang, "balance_prompt_reason")
+	msg := tgbotapi.NewMessage(message.From.ID, text)
+	h.bot.Send(msg)
+}
+
+// parseBalanceChange разбирает ввод вида +5, -2.5 или =10
+func parseBalanceChange(input string) (string, float64, bool) {
+	input = strings.ReplaceAll(strings.TrimSpace(input), ",", ".")
+	if len(input) < 2 {
+		return "", 0, false
+	}
+
+	op := input[:1]
+	if op != "+" && op != "-" && op != "=" {
+		return "", 0, false
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(input[1:]), 64)
+	if err != nil || value < 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return "", 0, false
+	}
+
+	return op, value, true
+}
+
+func (h *AdminHandler) handleBalanceReason(message *tgbotapi.Message, lang string, session *models.UserSession) {
+	reason := strings.TrimSpace(message.Text)
+	if reason == "" {
+		text := h.loc.Get(lang, "balance_prompt_reason")
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
 		return
 	}
 
-	text := h.loc.Get(lang, "balance_update_success", userID, amount)
+	userID := session.AwaitingBalanceUserID
+	targetUser, err := h.db.GetUser(userID)
+	if err != nil || targetUser == nil {
+		text := h.loc.Get(lang, "balance_user_not_found", localization.Args{"User": userID})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		delete(h.sessions, message.From.ID)
+		return
+	}
+
+	session.BalanceReason = reason
+	session.State = "awaiting_balance_confirm"
+
+	newBalance := database.ApplyBalanceOp(targetUser.Balance, session.BalanceOp, session.BalanceValue)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_confirm"), "admin_balance_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_cancel"), "admin_balance_cancel"),
+		),
+	)
+
+	text := h.loc.Get(lang, "balance_confirm", localization.Args{
+		"UserID":     userID,
+		"OldBalance": targetUser.Balance,
+		"NewBalance": newBalance,
+		"Reason":     html.EscapeString(reason),
+	})
 	msg := tgbotapi.NewMessage(message.From.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
 	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleBalanceConfirm(query *tgbotapi.CallbackQuery, lang string, confirmed bool) {
+	adminID := query.From.ID
+
+	session, exists := h.sessions[adminID]
+	if !exists || session.State != "awaiting_balance_confirm" {
+		return
+	}
+
+	// Убираем кнопки, чтобы изменение нельзя было применить повторно
+	h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(adminID, query.Message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
 
 	// Очищаем сессию
-	delete(h.sessions, message.From.ID)
+	delete(h.sessions, adminID)
+
+	if !confirmed {
+		text := h.loc.Get(lang, "cancel_operation")
+		msg := tgbotapi.NewMessage(adminID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	userID := session.AwaitingBalanceUserID
+	oldBalance, newBalance, err := h.db.AdjustBalance(userID, session.BalanceOp, session.BalanceValue, adminID, session.BalanceReason)
+	if err != nil {
+		if err == database.ErrNegativeBalance {
+			text := h.loc.Get(lang, "balance_negative")
+			msg := tgbotapi.NewMessage(adminID, text)
+			h.bot.Send(msg)
+			return
+		}
+		log.Printf("Error updating balance for user %d: %v", userID, err)
+		return
+	}
+
+	h.audit(adminID, models.AuditBalanceAdjust, &userID, map[string]interface{}{
+		"op":     session.BalanceOp,
+		"value":  session.BalanceValue,
+		"old":    oldBalance,
+		"new":    newBalance,
+		"reason": session.BalanceReason,
+	})
+
+	text := h.loc.Get(lang, "balance_update_success",
+		localization.Args{"UserID": userID, "OldBalance": oldBalance, "NewBalance": newBalance})
+	msg := tgbotapi.NewMessage(adminID, text)
+	h.bot.Send(msg)
 }
 
 func (h *AdminHandler) handleCancel(userID int64) {
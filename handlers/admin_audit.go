@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const auditPageSize = 10
+
+// audit записывает действие администратора; ошибки записи только логируются
+func (h *AdminHandler) audit(actorID int64, action string, targetID *int64, params map[string]interface{}) {
+	if err := h.db.LogAdminAction(actorID, action, targetID, params); err != nil {
+		log.Printf("Error writing audit entry %s for admin %d: %v", action, actorID, err)
+	}
+}
+
+// HandleAuditCommand обрабатывает /audit [actor=<id>] [action=<name>] [target=<id>] [days=<n>]
+func (h *AdminHandler) HandleAuditCommand(update tgbotapi.Update) {
+	userID := update.Message.From.ID
+
+	if !h.isAdmin(userID) {
+		return
+	}
+
+	lang := h.adminLanguage(userID)
+
+	if !h.can(userID, models.PermViewAudit) {
+		h.sendNoPermission(userID, lang)
+		return
+	}
+
+	filter, err := parseAuditFilter(update.Message.CommandArguments())
+	if err != nil {
+		text := h.loc.Get(lang, "audit_usage")
+		msg := tgbotapi.NewMessage(userID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+		return
+	}
+
+	h.auditFilters[userID] = filter
+	h.sendAuditPage(userID, lang, 0, 0)
+}
+
+func parseAuditFilter(args string) (models.AuditFilter, error) {
+	var filter models.AuditFilter
+
+	for _, field := range strings.Fields(args) {
+		key, value, found := strings.Cut(field, "=")
+		if !found || value == "" {
+			return filter, fmt.Errorf("invalid filter %q", field)
+		}
+
+		switch key {
+		case "actor", "target":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			if key == "actor" {
+				filter.ActorID = id
+			} else {
+				filter.TargetID = id
+			}
+		case "action":
+			filter.Action = value
+		case "days":
+			days, err := strconv.Atoi(value)
+			if err != nil || days <= 0 {
+				return filter, fmt.Errorf("invalid days %q", value)
+			}
+			filter.Since = time.Now().AddDate(0, 0, -days)
+		default:
+			return filter, fmt.Errorf("unknown filter %q", key)
+		}
+	}
+
+	return filter, nil
+}
+
+func (h *AdminHandler) handleAuditCallback(query *tgbotapi.CallbackQuery, lang string) {
+	adminID := query.From.ID
+
+	switch {
+	case query.Data == "admin_audit":
+		// Из меню открываем журнал без фильтров
+		h.auditFilters[adminID] = models.AuditFilter{}
+		h.sendAuditPage(adminID, lang, 0, 0)
+	case query.Data == "admin_audit_export":
+		h.handleAuditExport(adminID, lang)
+	case strings.HasPrefix(query.Data, "admin_audit_page_"):
+		page, err := strconv.Atoi(strings.TrimPrefix(query.Data, "admin_audit_page_"))
+		if err != nil || page < 0 {
+			return
+		}
+		h.sendAuditPage(adminID, lang, page, query.Message.MessageID)
+	}
+}
+
+// sendAuditPage показывает страницу журнала; при messageID != 0 редактирует существующее сообщение
+func (h *AdminHandler) sendAuditPage(adminID int64, lang string, page, messageID int) {
+	filter := h.auditFilters[adminID]
+
+	total, err := h.db.CountAuditLog(filter)
+	if err != nil {
+		log.Printf("Error counting audit log: %v", err)
+		return
+	}
+
+	entries, err := h.db.GetAuditLog(filter, auditPageSize, page*auditPageSize)
+	if err != nil {
+		log.Printf("Error getting audit log: %v", err)
+		return
+	}
+
+	if messageID == 0 {
+		h.audit(adminID, models.AuditViewAuditLog, nil, auditFilterParams(filter))
+	}
+
+	pages := (total + auditPageSize - 1) / auditPageSize
+	if pages == 0 {
+		pages = 1
+	}
+
+	var text strings.Builder
+	text.WriteString(h.loc.Get(lang, "audit_title", localization.Args{"Page": page + 1, "Pages": pages, "Total": total}) + "\n\n")
+	if len(entries) == 0 {
+		text.WriteString(h.loc.Get(lang, "audit_empty"))
+	}
+	for _, entry := range entries {
+		text.WriteString(formatAuditEntry(entry) + "\n")
+	}
+
+	var navigation []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("⬅️", fmt.Sprintf("admin_audit_page_%d", page-1)))
+	}
+	if page+1 < pages {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("➡️", fmt.Sprintf("admin_audit_page_%d", page+1)))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_audit_export"), "admin_audit_export"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(adminID, messageID, text.String(), keyboard)
+		edit.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(edit)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(adminID, text.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+func formatAuditEntry(entry models.AuditEntry) string {
+	line := fmt.Sprintf("#%d · %s · <code>%d</code> · <b>%s</b>",
+		entry.ID, entry.CreatedAt.Format(cardDateLayout), entry.ActorID, html.EscapeString(entry.Action))
+
+	if entry.TargetID != nil {
+		line += fmt.Sprintf(" → <code>%d</code>", *entry.TargetID)
+	}
+
+	if len(entry.Params) > 0 {
+		params, _ := json.Marshal(entry.Params)
+		line += "\n<i>" + html.EscapeString(truncate(string(params), 200)) + "</i>"
+	}
+
+	return line
+}
+
+func auditFilterParams(filter models.AuditFilter) map[string]interface{} {
+	params := make(map[string]interface{})
+	if filter.ActorID != 0 {
+		params["actor"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		params["action"] = filter.Action
+	}
+	if filter.TargetID != 0 {
+		params["target"] = filter.TargetID
+	}
+	if !filter.Since.IsZero() {
+		params["since"] = filter.Since.Format(cardDateLayout)
+	}
+	return params
+}
+
+func (h *AdminHandler) handleAuditExport(adminID int64, lang string) {
+	filter := h.auditFilters[adminID]
+
+	entries, err := h.db.GetAuditLog(filter, 0, 0)
+	if err != nil {
+		log.Printf("Error exporting audit log: %v", err)
+		return
+	}
+
+	if len(entries) == 0 {
+		text := h.loc.Get(lang, "audit_empty")
+		msg := tgbotapi.NewMessage(adminID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	jsonData, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		log.Printf("Error marshaling audit log: %v", err)
+		return
+	}
+
+	h.audit(adminID, models.AuditExportAuditLog, nil, auditFilterParams(filter))
+
+	fileBytes := tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("audit_%s.json", time.Now().Format("2006-01-02")),
+		Bytes: jsonData,
+	}
+
+	doc := tgbotapi.NewDocument(adminID, fileBytes)
+	doc.Caption = h.loc.Get(lang, "audit_export_caption", localization.Args{"Count": len(entries)})
+	h.bot.Send(doc)
+}
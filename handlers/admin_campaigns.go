@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func campaignLink(botName, slug string) string {
+	return fmt.Sprintf("https://t.me/%s?start=c_%s", botName, slug)
+}
+
+// HandleCampaignCommand обрабатывает /campaign <slug> [owner=<id>] [reward=<сумма>] [bonus=<сумма>]
+func (h *AdminHandler) HandleCampaignCommand(update tgbotapi.Update) {
+	userID := update.Message.From.ID
+
+	if !h.isAdmin(userID) {
+		return
+	}
+
+	lang := h.adminLanguage(userID)
+
+	if !h.can(userID, models.PermManageCampaigns) {
+		h.sendNoPermission(userID, lang)
+		return
+	}
+
+	campaign, ok := parseCampaignArgs(strings.Fields(update.Message.CommandArguments()))
+	if !ok {
+		h.sendCampaignUsage(userID, lang)
+		return
+	}
+	campaign.CreatedBy = userID
+
+	if campaign.OwnerID != nil {
+		owner, err := h.db.GetUser(*campaign.OwnerID)
+		if err != nil || owner == nil {
+			text := h.loc.Get(lang, "balance_user_not_found", localization.Args{"User": *campaign.OwnerID})
+			msg := tgbotapi.NewMessage(userID, text)
+			h.bot.Send(msg)
+			return
+		}
+	}
+
+	if err := h.db.CreateCampaign(campaign); err != nil {
+		switch err {
+		case database.ErrCampaignExists:
+			text := h.loc.Get(lang, "campaign_exists")
+			msg := tgbotapi.NewMessage(userID, text)
+			h.bot.Send(msg)
+		case database.ErrInvalidCampaign:
+			h.sendCampaignUsage(userID, lang)
+		default:
+			log.Printf("Error creating campaign %q: %v", campaign.Slug, err)
+		}
+		return
+	}
+
+	details := map[string]interface{}{
+		"slug":          campaign.Slug,
+		"welcome_bonus": campaign.WelcomeBonus,
+	}
+	if campaign.RewardAmount != nil {
+		details["reward_amount"] = *campaign.RewardAmount
+	}
+	h.audit(userID, models.AuditCampaignCreate, campaign.OwnerID, details)
+
+	text := h.loc.Get(lang, "campaign_created",
+		localization.Args{"Slug": campaign.Slug, "Link": campaignLink(h.bot.Self.UserName, campaign.Slug)})
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	h.bot.Send(msg)
+}
+
+// parseCampaignArgs разбирает аргументы /campaign
+func parseCampaignArgs(args []string) (*models.Campaign, bool) {
+	if len(args) == 0 || !database.ValidCampaignSlug(args[0]) {
+		return nil, false
+	}
+
+	campaign := &models.Campaign{Slug: args[0]}
+	for _, arg := range args[1:] {
+		key, value, found := strings.Cut(arg, "=")
+		if !found {
+			return nil, false
+		}
+
+		switch key {
+		case "owner":
+			ownerID, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			campaign.OwnerID = &ownerID
+		case "reward":
+			amount, ok := parseCampaignAmount(value)
+			if !ok {
+				return nil, false
+			}
+			campaign.RewardAmount = &amount
+		case "bonus":
+			amount, ok := parseCampaignAmount(value)
+			if !ok {
+				return nil, false
+			}
+			campaign.WelcomeBonus = amount
+		default:
+			return nil, false
+		}
+	}
+
+	return campaign, true
+}
+
+func parseCampaignAmount(value string) (float64, bool) {
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) || amount < 0 {
+		return 0, false
+	}
+	return amount, true
+}
+
+func (h *AdminHandler) sendCampaignUsage(userID int64, lang string) {
+	text := h.loc.Get(lang, "campaign_usage")
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleCampaignStats(query *tgbotapi.CallbackQuery, lang string) {
+	stats, err := h.db.CampaignStats()
+	if err != nil {
+		log.Printf("Error getting campaign stats: %v", err)
+		return
+	}
+
+	h.audit(query.From.ID, models.AuditViewStats, nil, map[string]interface{}{
+		"view": "campaigns",
+	})
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("<b>%s</b>\n\n", h.loc.Get(lang, "campaigns_title")))
+	if len(stats) == 0 {
+		b.WriteString(h.loc.Get(lang, "campaigns_empty"))
+	}
+	for _, s := range stats {
+		b.WriteString(h.loc.Get(lang, "campaign_stats_line", localization.Args{
+			"Slug":        s.Slug,
+			"Link":        campaignLink(h.bot.Self.UserName, s.Slug),
+			"Signups":     s.Signups,
+			"Confirmed":   s.Confirmed,
+			"Withdrawals": s.Withdrawals,
+			"Withdrawn":   s.WithdrawnTotal,
+			"Cost":        s.Cost,
+		}))
+		if s.OwnerID != nil {
+			b.WriteString(h.loc.Get(lang, "campaign_owner_line", localization.Args{"Owner": codeLabel(*s.OwnerID)}))
+		}
+		b.WriteString("\n")
+	}
+
+	msg := tgbotapi.NewMessage(query.From.ID, b.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	h.bot.Send(msg)
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const defaultContestWinners = 3
+
+// HandleContestCommand обрабатывает /contest, /contest start <дней> <фонд> <referrals|earned> [победителей]
+// и /contest cancel
+func (h *AdminHandler) HandleContestCommand(update tgbotapi.Update) {
+	userID := update.Message.From.ID
+
+	if !h.isAdmin(userID) {
+		return
+	}
+
+	lang := h.adminLanguage(userID)
+
+	if !h.can(userID, models.PermManageContests) {
+		h.sendNoPermission(userID, lang)
+		return
+	}
+
+	args := strings.Fields(update.Message.CommandArguments())
+	switch {
+	case len(args) == 0:
+		h.sendContestStatus(userID, lang)
+	case args[0] == "start":
+		h.startContest(userID, lang, args[1:])
+	case args[0] == "cancel" && len(args) == 1:
+		h.cancelContest(userID, lang)
+	default:
+		h.sendContestUsage(userID, lang)
+	}
+}
+
+func (h *AdminHandler) sendContestUsage(userID int64, lang string) {
+	text := h.loc.Get(lang, "contest_usage")
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) sendContestStatus(userID int64, lang string) {
+	contest, err := h.db.GetActiveContest()
+	if err != nil {
+		log.Printf("Error getting active contest: %v", err)
+		return
+	}
+	if contest == nil {
+		h.sendContestUsage(userID, lang)
+		return
+	}
+
+	text, err := contestRankingText(h.db, h.loc, lang, contest)
+	if err != nil {
+		log.Printf("Error getting contest ranking: %v", err)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) startContest(userID int64, lang string, args []string) {
+	if len(args) < 3 || len(args) > 4 {
+		h.sendContestUsage(userID, lang)
+		return
+	}
+
+	days, err := strconv.ParseFloat(args[0], 64)
+	if err != nil || days <= 0 {
+		h.sendContestUsage(userID, lang)
+		return
+	}
+
+	pool, err := strconv.ParseFloat(strings.ReplaceAll(args[1], ",", "."), 64)
+	if err != nil || math.IsNaN(pool) || pool <= 0 {
+		h.sendContestUsage(userID, lang)
+		return
+	}
+
+	metric := args[2]
+	if metric != models.MetricReferrals && metric != models.MetricEarned {
+		h.sendContestUsage(userID, lang)
+		return
+	}
+
+	winners := defaultContestWinners
+	if len(args) == 4 {
+		winners, err = strconv.Atoi(args[3])
+		if err != nil || winners < 1 || winners > 100 {
+			h.sendContestUsage(userID, lang)
+			return
+		}
+	}
+
+	duration := time.Duration(days * float64(24*time.Hour))
+	contest, err := h.db.CreateContest(metric, pool, winners, duration, userID)
+	if err != nil {
+		if err == database.ErrContestActive {
+			text := h.loc.Get(lang, "contest_already_active")
+			msg := tgbotapi.NewMessage(userID, text)
+			h.bot.Send(msg)
+			return
+		}
+		log.Printf("Error creating contest: %v", err)
+		return
+	}
+
+	h.audit(userID, models.AuditContestStart, nil, map[string]interface{}{
+		"contest_id": contest.ID,
+		"metric":     contest.Metric,
+		"prize_pool": contest.PrizePool,
+		"winners":    contest.Winners,
+		"ends_at":    contest.EndsAt.Format(cardDateLayout),
+	})
+
+	text := h.loc.Get(lang, "contest_started", localization.Args{
+		"ID":        contest.ID,
+		"Metric":    h.loc.Get(lang, "metric_"+contest.Metric),
+		"PrizePool": contest.PrizePool,
+		"Count":     contest.Winners,
+		"EndsAt":    contest.EndsAt.Format(cardDateLayout),
+	})
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) cancelContest(userID int64, lang string) {
+	contest, err := h.db.GetActiveContest()
+	if err != nil {
+		log.Printf("Error getting active contest: %v", err)
+		return
+	}
+	if contest == nil {
+		h.sendContestUsage(userID, lang)
+		return
+	}
+
+	if err := h.db.CancelContest(contest.ID); err != nil {
+		log.Printf("Error cancelling contest %d: %v", contest.ID, err)
+		return
+	}
+
+	h.audit(userID, models.AuditContestCancel, nil, map[string]interface{}{
+		"contest_id": contest.ID,
+	})
+
+	text := h.loc.Get(lang, "contest_cancelled", localization.Args{"ID": contest.ID})
+	msg := tgbotapi.NewMessage(userID, text)
+	h.bot.Send(msg)
+}
+
+// RunContests периодически закрывает завершившиеся конкурсы, начисляет призы и объявляет победителей
+func (h *AdminHandler) RunContests(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		contests, err := h.db.GetDueContests()
+		if err != nil {
+			log.Printf("Error getting finished contests: %v", err)
+			continue
+		}
+
+		for _, contest := range contests {
+			prizes, err := h.db.FinishContest(contest.ID)
+			if err != nil {
+				log.Printf("Error finishing contest %d: %v", contest.ID, err)
+				continue
+			}
+			h.announceContest(&contest, prizes)
+		}
+	}
+}
+
+func (h *AdminHandler) announceContest(contest *models.Contest, prizes []models.ContestPrize) {
+	languages, err := h.db.GetActiveUserLanguages()
+	if err != nil {
+		log.Printf("Error getting users for contest %d announcement: %v", contest.ID, err)
+		return
+	}
+
+	// Текст объявления собираем один раз на каждый язык
+	texts := make(map[string]string)
+	announcement := func(lang string) string {
+		if text, ok := texts[lang]; ok {
+			return text
+		}
+
+		var b strings.Builder
+		b.WriteString(h.loc.Get(lang, "contest_finished",
+			localization.Args{"Metric": h.loc.Get(lang, "metric_"+contest.Metric), "PrizePool": contest.PrizePool}))
+		if len(prizes) == 0 {
+			b.WriteString("\n")
+			b.WriteString(h.loc.Get(lang, "top_empty"))
+		}
+		for _, prize := range prizes {
+			b.WriteString("\n")
+			b.WriteString(h.loc.Get(lang, "contest_winner_line", localization.Args{
+				"Rank":  prize.Rank,
+				"User":  codeLabel(prize.UserID),
+				"Value": formatRankValue(h.loc, lang, contest.Metric, prize.Value),
+				"Prize": prize.Amount,
+			}))
+		}
+
+		texts[lang] = b.String()
+		return texts[lang]
+	}
+
+	won := make(map[int64]models.ContestPrize)
+	for _, prize := range prizes {
+		won[prize.UserID] = prize
+	}
+
+	for userID, lang := range languages {
+		text := announcement(lang)
+		if prize, ok := won[userID]; ok {
+			text += "\n\n" + h.loc.Get(lang, "contest_you_won", localization.Args{"Rank": prize.Rank, "Prize": prize.Amount})
+		}
+
+		msg := tgbotapi.NewMessage(userID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		if _, err := h.bot.Send(msg); err != nil && isBlockedError(err) {
+			if err := h.db.SetUserActive(userID, false); err != nil {
+				log.Printf("Error marking user %d inactive: %v", userID, err)
+			}
+		}
+	}
+
+	log.Printf("Contest %d finished with %d winners", contest.ID, len(prizes))
+}
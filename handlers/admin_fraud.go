@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *AdminHandler) handleFraudDecision(query *tgbotapi.CallbackQuery, lang string) {
+	action, rest, found := strings.Cut(strings.TrimPrefix(query.Data, "admin_fraud_"), "_")
+	if !found {
+		return
+	}
+
+	referredID, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return
+	}
+
+	adminID := query.From.ID
+	var auditAction string
+	var rewards []models.ReferralReward
+
+	switch action {
+	case "release":
+		rewards, err = h.db.ReleaseHeldReferral(referredID, adminID, referralRewards(h.db, h.config, referredID))
+		auditAction = models.AuditReferralRelease
+	case "reject":
+		err = h.db.RejectHeldReferral(referredID, adminID)
+		auditAction = models.AuditReferralReject
+	default:
+		return
+	}
+
+	if err != nil {
+		if err == database.ErrReferralNotHeld {
+			text := h.loc.Get(lang, "referral_already_reviewed", localization.Args{"UserID": referredID})
+			msg := tgbotapi.NewMessage(adminID, text)
+			h.bot.Send(msg)
+			return
+		}
+		log.Printf("Error reviewing held referral %d: %v", referredID, err)
+		return
+	}
+
+	h.audit(adminID, auditAction, &referredID, nil)
+
+	// Убираем кнопки и отмечаем решение под уведомлением
+	h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(adminID, query.Message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+	text := h.loc.Get(lang, "referral_reviewed_admin",
+		localization.Args{"UserID": referredID, "Status": h.loc.Get(lang, "referral_status_"+action)})
+	h.bot.Send(tgbotapi.NewMessage(adminID, text))
+
+	notifyReferralRewards(h.bot, h.db, h.loc, rewards)
+
+	if action == "release" {
+		// Освобожденный реферал может разблокировать бонусы обоих пользователей
+		unlockWelcomeBonus(h.bot, h.db, h.loc, h.config.WelcomeBonusUnlock, referredID)
+		if referred, _ := h.db.GetUser(referredID); referred != nil && referred.ReferredBy != nil {
+			unlockWelcomeBonus(h.bot, h.db, h.loc, h.config.WelcomeBonusUnlock, *referred.ReferredBy)
+		}
+	}
+}
+
+// RunFraudReport раз в interval отправляет администраторам список рефереров,
+// чьи рефералы были задержаны антифродом за этот период
+func (h *AdminHandler) RunFraudReport(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		referrers, err := h.db.FlaggedReferrers(time.Now().Add(-interval))
+		if err != nil {
+			log.Printf("Error building fraud report: %v", err)
+			continue
+		}
+		if len(referrers) == 0 {
+			continue
+		}
+
+		adminIDs, err := h.db.GetAdminIDsWithPermission(models.PermManageUsers)
+		if err != nil {
+			log.Printf("Error getting admins for fraud report: %v", err)
+			continue
+		}
+
+		for _, adminID := range adminIDs {
+			lang := h.adminLanguage(adminID)
+
+			var b strings.Builder
+			b.WriteString(h.loc.Get(lang, "fraud_report_title", localization.Args{"Total": len(referrers)}))
+			for _, referrer := range referrers {
+				b.WriteString("\n")
+				b.WriteString(h.loc.Get(lang, "fraud_report_line", localization.Args{
+					"UserID":   referrer.ReferrerID,
+					"Held":     referrer.Flagged,
+					"MaxScore": referrer.MaxScore,
+				}))
+			}
+
+			msg := tgbotapi.NewMessage(adminID, truncate(b.String(), 4000))
+			msg.ParseMode = tgbotapi.ModeHTML
+			h.bot.Send(msg)
+		}
+	}
+}
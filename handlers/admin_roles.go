@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// getAdmin возвращает администратора или nil для обычного пользователя
+func (h *AdminHandler) getAdmin(userID int64) *models.Admin {
+	admin, err := h.db.GetAdmin(userID)
+	if err != nil {
+		log.Printf("Error getting admin %d: %v", userID, err)
+		return nil
+	}
+	return admin
+}
+
+func (h *AdminHandler) isAdmin(userID int64) bool {
+	return h.getAdmin(userID) != nil
+}
+
+func (h *AdminHandler) can(userID int64, permission string) bool {
+	admin := h.getAdmin(userID)
+	return admin != nil && admin.Role.Has(permission)
+}
+
+// callbackPermission возвращает право, необходимое для обработки callback
+func callbackPermission(data string) string {
+	switch {
+	case data == "admin_user_count", data == "admin_stats", data == "admin_campaigns":
+		return models.PermViewStats
+	case data == "admin_db_download":
+		return models.PermExportDB
+	case data == "admin_mass_message":
+		return models.PermBroadcast
+	case data == "admin_change_balance", data == "admin_balance_confirm", data == "admin_balance_cancel",
+		strings.HasPrefix(data, "admin_card_balance_"):
+		return models.PermAdjustBalance
+	case data == "admin_user_search", strings.HasPrefix(data, "admin_card_"), strings.HasPrefix(data, "admin_fraud_"):
+		return models.PermManageUsers
+	case strings.HasPrefix(data, "admin_wd_"):
+		return models.PermApproveWithdrawals
+	case strings.HasPrefix(data, "admin_text_"):
+		return models.PermManageTexts
+	case strings.HasPrefix(data, "admin_audit"):
+		return models.PermViewAudit
+	case strings.HasPrefix(data, "admin_adm"), strings.HasPrefix(data, "admin_role"):
+		return models.PermManageAdmins
+	default:
+		// Новый callback недоступен никому, пока ему не назначено право
+		return ""
+	}
+}
+
+// statePermission возвращает право, необходимое для продолжения диалога администратора
+func statePermission(state string) string {
+	switch state {
+	case "awaiting_broadcast_message":
+		return models.PermBroadcast
+	case "awaiting_balance_user_id", "awaiting_balance_amount", "awaiting_balance_reason", "awaiting_balance_confirm":
+		return models.PermAdjustBalance
+	case "awaiting_user_search", "awaiting_direct_message":
+		return models.PermManageUsers
+	case "awaiting_admin_id":
+		return models.PermManageAdmins
+	case "awaiting_text_override", "awaiting_text_confirm":
+		return models.PermManageTexts
+	default:
+		return ""
+	}
+}
+
+func (h *AdminHandler) sendNoPermission(userID int64, lang string) {
+	text := h.loc.Get(lang, "no_permission")
+	msg := tgbotapi.NewMessage(userID, text)
+	h.bot.Send(msg)
+}
+
+// isOwner сообщает, что у администратора роль владельца. Только владельцы могут
+// назначать и снимать других владельцев, в том числе повышать самих себя
+func (h *AdminHandler) isOwner(userID int64) bool {
+	admin := h.getAdmin(userID)
+	return admin != nil && admin.Role.Name == models.RoleOwner
+}
+
+func (h *AdminHandler) sendOwnerOnly(userID int64, lang string) {
+	text := h.loc.Get(lang, "admins_owner_only")
+	msg := tgbotapi.NewMessage(userID, text)
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) roleName(lang, role string) string {
+	return h.loc.Get(lang, "role_"+role)
+}
+
+func (h *AdminHandler) handleAdminsCallback(query *tgbotapi.CallbackQuery, lang string) {
+	adminID := query.From.ID
+	data := query.Data
+
+	switch {
+	case data == "admin_admins":
+		h.sendAdminList(adminID, lang)
+	case data == "admin_admin_add":
+		h.sessions[adminID] = &models.UserSession{
+			State: "awaiting_admin_id",
+		}
+
+		text := h.loc.Get(lang, "admins_prompt_id")
+		msg := tgbotapi.NewMessage(adminID, text)
+		h.bot.Send(msg)
+	case strings.HasPrefix(data, "admin_adm_"):
+		userID, err := strconv.ParseInt(strings.TrimPrefix(data, "admin_adm_"), 10, 64)
+		if err != nil {
+			return
+		}
+		h.sendRolePicker(adminID, lang, userID)
+	case strings.HasPrefix(data, "admin_admrole_"):
+		role, userID, ok := parseRoleCallback(strings.TrimPrefix(data, "admin_admrole_"))
+		if !ok {
+			return
+		}
+		h.handleSetAdminRole(adminID, lang, userID, role)
+	case strings.HasPrefix(data, "admin_admdel_"):
+		userID, err := strconv.ParseInt(strings.TrimPrefix(data, "admin_admdel_"), 10, 64)
+		if err != nil {
+			return
+		}
+		h.handleRemoveAdmin(adminID, lang, userID)
+	case data == "admin_roles":
+		h.sendRoleList(adminID, lang)
+	case strings.HasPrefix(data, "admin_role_"):
+		role, err := h.db.GetRole(strings.TrimPrefix(data, "admin_role_"))
+		if err != nil || role == nil {
+			return
+		}
+		h.sendRolePermissions(adminID, lang, role, 0)
+	case strings.HasPrefix(data, "admin_roleperm_"):
+		h.handleTogglePermission(query, lang)
+	}
+}
+
+// parseRoleCallback разбирает "<role>_<user_id>"
+func parseRoleCallback(rest string) (string, int64, bool) {
+	sep := strings.LastIndex(rest, "_")
+	if sep < 0 {
+		return "", 0, false
+	}
+
+	userID, err := strconv.ParseInt(rest[sep+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return rest[:sep], userID, true
+}
+
+func (h *AdminHandler) sendAdminList(adminID int64, lang string) {
+	admins, err := h.db.ListAdmins()
+	if err != nil {
+		log.Printf("Error listing admins: %v", err)
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(h.loc.Get(lang, "admins_title") + "\n\n")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, admin := range admins {
+		text.WriteString(fmt.Sprintf("<code>%d</code> · %s\n", admin.UserID, h.roleName(lang, admin.Role.Name)))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%d · %s", admin.UserID, h.roleName(lang, admin.Role.Name)),
+				fmt.Sprintf("admin_adm_%d", admin.UserID)),
+		))
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_admin_add"), "admin_admin_add"),
+		tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_roles"), "admin_roles"),
+	))
+
+	msg := tgbotapi.NewMessage(adminID, text.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleAdminID(message *tgbotapi.Message, lang string) {
+	userID, err := strconv.ParseInt(strings.TrimSpace(message.Text), 10, 64)
+	if err != nil {
+		text := h.loc.Get(lang, "balance_user_not_found", localization.Args{"User": message.Text})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	delete(h.sessions, message.From.ID)
+	h.sendRolePicker(message.From.ID, lang, userID)
+}
+
+func (h *AdminHandler) sendRolePicker(adminID int64, lang string, userID int64) {
+	roles, err := h.db.ListRoles()
+	if err != nil {
+		log.Printf("Error listing roles: %v", err)
+		return
+	}
+
+	// Роль владельца назначают и снимают только владельцы
+	ownerAccess := h.isOwner(adminID)
+	target := h.getAdmin(userID)
+	if target != nil && target.Role.Name == models.RoleOwner && !ownerAccess {
+		h.sendOwnerOnly(adminID, lang)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, role := range roles {
+		if role.Name == models.RoleOwner && !ownerAccess {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.roleName(lang, role.Name),
+				fmt.Sprintf("admin_admrole_%s_%d", role.Name, userID)),
+		))
+	}
+
+	if target != nil {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_admin_remove"), fmt.Sprintf("admin_admdel_%d", userID)),
+		))
+	}
+
+	text := h.loc.Get(lang, "admins_pick_role", localization.Args{"UserID": userID})
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleSetAdminRole(adminID int64, lang string, userID int64, role string) {
+	if r, err := h.db.GetRole(role); err != nil || r == nil {
+		return
+	}
+
+	if (role == models.RoleOwner || h.isOwner(userID)) && !h.isOwner(adminID) {
+		h.sendOwnerOnly(adminID, lang)
+		return
+	}
+
+	if err := h.db.SetAdminRole(userID, role, adminID); err != nil {
+		if err == database.ErrLastOwner {
+			text := h.loc.Get(lang, "admins_last_owner")
+			msg := tgbotapi.NewMessage(adminID, text)
+			h.bot.Send(msg)
+			return
+		}
+		log.Printf("Error setting role %s for %d: %v", role, userID, err)
+		return
+	}
+
+	h.audit(adminID, models.AuditAdminRoleSet, &userID, map[string]interface{}{"role": role})
+
+	text := h.loc.Get(lang, "admins_role_set", localization.Args{"UserID": userID, "Role": h.roleName(lang, role)})
+	msg := tgbotapi.NewMessage(adminID, text)
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleRemoveAdmin(adminID int64, lang string, userID int64) {
+	if h.isOwner(userID) && !h.isOwner(adminID) {
+		h.sendOwnerOnly(adminID, lang)
+		return
+	}
+
+	if err := h.db.RemoveAdmin(userID); err != nil {
+		if err == database.ErrLastOwner {
+			text := h.loc.Get(lang, "admins_last_owner")
+			msg := tgbotapi.NewMessage(adminID, text)
+			h.bot.Send(msg)
+			return
+		}
+		log.Printf("Error removing admin %d: %v", userID, err)
+		return
+	}
+
+	h.audit(adminID, models.AuditAdminRemove, &userID, nil)
+
+	text := h.loc.Get(lang, "admins_removed", localization.Args{"UserID": userID})
+	msg := tgbotapi.NewMessage(adminID, text)
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) sendRoleList(adminID int64, lang string) {
+	roles, err := h.db.ListRoles()
+	if err != nil {
+		log.Printf("Error listing roles: %v", err)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, role := range roles {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.roleName(lang, role.Name), "admin_role_"+role.Name),
+		))
+	}
+
+	text := h.loc.Get(lang, "roles_title")
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.bot.Send(msg)
+}
+
+// sendRolePermissions показывает права роли с переключателями; при messageID != 0 редактирует сообщение
+func (h *AdminHandler) sendRolePermissions(adminID int64, lang string, role *models.Role, messageID int) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, permission := range models.AllPermissions {
+		mark := "▫️"
+		if role.Has(permission) {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(mark+" "+h.loc.Get(lang, "perm_"+permission),
+				fmt.Sprintf("admin_roleperm_%s_%s", role.Name, permission)),
+		))
+	}
+
+	text := h.loc.Get(lang, "role_permissions_title", localization.Args{"Role": h.roleName(lang, role.Name)})
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(adminID, messageID, text, keyboard)
+		h.bot.Send(edit)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleTogglePermission(query *tgbotapi.CallbackQuery, lang string) {
+	roleName, permission, found := strings.Cut(strings.TrimPrefix(query.Data, "admin_roleperm_"), "_")
+	if !found {
+		return
+	}
+
+	role, err := h.db.GetRole(roleName)
+	if err != nil || role == nil {
+		return
+	}
+
+	// Права владельцев меняют только сами владельцы
+	if role.Name == models.RoleOwner && !h.isOwner(query.From.ID) {
+		h.sendOwnerOnly(query.From.ID, lang)
+		return
+	}
+
+	// Владелец всегда может управлять администраторами и видеть журнал, иначе можно
+	// потерять доступ к боту
+	if role.Name == models.RoleOwner && (permission == models.PermManageAdmins || permission == models.PermViewAudit) {
+		text := h.loc.Get(lang, "admins_last_owner")
+		msg := tgbotapi.NewMessage(query.From.ID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	var permissions []string
+	enabled := !role.Has(permission)
+	for _, p := range models.AllPermissions {
+		if (p == permission && enabled) || (p != permission && role.Has(p)) {
+			permissions = append(permissions, p)
+		}
+	}
+
+	if err := h.db.SetRolePermissions(role.Name, permissions); err != nil {
+		log.Printf("Error updating permissions for role %s: %v", role.Name, err)
+		return
+	}
+	role.Permissions = permissions
+
+	h.audit(query.From.ID, models.AuditRolePermission, nil, map[string]interface{}{
+		"role":       role.Name,
+		"permission": permission,
+		"enabled":    enabled,
+	})
+
+	h.sendRolePermissions(query.From.ID, lang, role, query.Message.MessageID)
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"html"
+	"log"
+	"sort"
+	"strings"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Длина текста перевода в карточке: карточка с двумя текстами должна поместиться в сообщение
+const textCardValueLimit = 1500
+
+func textCallback(action, textLang, key string) string {
+	return "admin_text_" + action + "_" + textLang + ":" + key
+}
+
+// parseTextCallback разбирает admin_text_<action>_<lang>:<key>
+func parseTextCallback(data, action string) (string, string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(data, "admin_text_"+action+"_"), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// HandleTextCommand обрабатывает /text <lang> <key>
+func (h *AdminHandler) HandleTextCommand(update tgbotapi.Update) {
+	adminID := update.Message.From.ID
+
+	if !h.isAdmin(adminID) {
+		return
+	}
+
+	lang := h.adminLanguage(adminID)
+
+	if !h.can(adminID, models.PermManageTexts) {
+		h.sendNoPermission(adminID, lang)
+		return
+	}
+
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) != 2 {
+		h.sendTextUsage(adminID, lang)
+		return
+	}
+
+	h.sendTextCard(adminID, lang, args[0], args[1])
+}
+
+// sendTextUsage показывает формат команды и список измененных текстов
+func (h *AdminHandler) sendTextUsage(adminID int64, lang string) {
+	overrides, err := h.db.TextOverrides()
+	if err != nil {
+		log.Printf("Error loading text overrides: %v", err)
+		return
+	}
+
+	var keys []string
+	for textLang, texts := range overrides {
+		for key := range texts {
+			keys = append(keys, "<code>"+html.EscapeString(textLang+" "+key)+"</code>")
+		}
+	}
+	sort.Strings(keys)
+
+	list := h.loc.Get(lang, "text_overrides_none")
+	if len(keys) > 0 {
+		list = strings.Join(keys, "\n")
+	}
+
+	text := h.loc.Get(lang, "text_usage",
+		localization.Args{"Languages": strings.Join(h.loc.GetSupportedLanguages(), ", "), "Edited": list})
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
+
+// sendTextCard показывает перевод ключа из файлов и текст администратора с кнопками правки
+func (h *AdminHandler) sendTextCard(adminID int64, lang, textLang, key string) {
+	if !h.loc.Has(textLang) {
+		text := h.loc.Get(lang, "text_unknown_language", localization.Args{"Lang": html.EscapeString(textLang)})
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+		return
+	}
+	if !h.loc.HasKey(key) {
+		text := h.loc.Get(lang, "text_unknown_key", localization.Args{"Key": html.EscapeString(key)})
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+		return
+	}
+
+	file, override, overridden := h.loc.Source(textLang, key)
+
+	var text strings.Builder
+	text.WriteString(h.loc.Get(lang, "text_card", localization.Args{
+		"Lang":  html.EscapeString(textLang),
+		"Key":   html.EscapeString(key),
+		"Value": html.EscapeString(truncate(file, textCardValueLimit)),
+	}))
+	if overridden {
+		text.WriteString("\n\n" + h.loc.Get(lang, "text_card_override", localization.Args{"Value": html.EscapeString(truncate(override, textCardValueLimit))}))
+	}
+
+	buttons := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_text_edit"), textCallback("edit", textLang, key)),
+	}
+	if overridden {
+		buttons = append(buttons,
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_text_revert"), textCallback("revert", textLang, key)))
+	}
+
+	msg := tgbotapi.NewMessage(adminID, text.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons)
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleTextCallback(query *tgbotapi.CallbackQuery, lang string) {
+	adminID := query.From.ID
+	data := query.Data
+
+	switch {
+	case data == "admin_text_save":
+		h.handleTextConfirm(query, lang, true)
+	case data == "admin_text_cancel":
+		h.handleTextConfirm(query, lang, false)
+	case strings.HasPrefix(data, "admin_text_edit_"):
+		textLang, key, ok := parseTextCallback(data, "edit")
+		if !ok {
+			return
+		}
+		h.sessions[adminID] = &models.UserSession{
+			State:    "awaiting_text_override",
+			TextLang: textLang,
+			TextKey:  key,
+		}
+
+		text := h.loc.Get(lang, "text_prompt", localization.Args{"Lang": html.EscapeString(textLang), "Key": html.EscapeString(key)})
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+	case strings.HasPrefix(data, "admin_text_revert_"):
+		textLang, key, ok := parseTextCallback(data, "revert")
+		if !ok {
+			return
+		}
+		h.revertText(adminID, lang, textLang, key)
+	}
+}
+
+func (h *AdminHandler) revertText(adminID int64, lang, textLang, key string) {
+	deleted, err := h.db.DeleteTextOverride(textLang, key)
+	if err != nil {
+		log.Printf("Error reverting text %s.%s: %v", textLang, key, err)
+		return
+	}
+	if !deleted {
+		text := h.loc.Get(lang, "text_not_overridden")
+		msg := tgbotapi.NewMessage(adminID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	h.loc.RemoveOverride(textLang, key)
+	h.audit(adminID, models.AuditTextRevert, nil, map[string]interface{}{"lang": textLang, "key": key})
+
+	text := h.loc.Get(lang, "text_reverted", localization.Args{"Lang": html.EscapeString(textLang), "Key": html.EscapeString(key)})
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+
+	h.sendTextCard(adminID, lang, textLang, key)
+}
+
+// handleTextOverride проверяет новый текст и показывает, как он будет выглядеть
+func (h *AdminHandler) handleTextOverride(message *tgbotapi.Message, lang string, session *models.UserSession) {
+	value := strings.TrimSpace(message.Text)
+	if value == "" {
+		text := h.loc.Get(lang, "text_prompt", localization.Args{"Lang": html.EscapeString(session.TextLang), "Key": html.EscapeString(session.TextKey)})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+		return
+	}
+
+	if err := h.loc.CheckOverride(session.TextLang, session.TextKey, value); err != nil {
+		h.sendTextInvalid(message.From.ID, lang, err)
+		return
+	}
+
+	text := h.loc.Get(lang, "text_preview", localization.Args{"Lang": html.EscapeString(session.TextLang), "Key": html.EscapeString(session.TextKey)})
+	msg := tgbotapi.NewMessage(message.From.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+
+	// Предпросмотр отправляется с той же HTML-разметкой, что и перевод, поэтому
+	// ошибки разметки видны до сохранения
+	preview := tgbotapi.NewMessage(message.From.ID, value)
+	preview.ParseMode = tgbotapi.ModeHTML
+	preview.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_confirm"), "admin_text_save"),
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_cancel"), "admin_text_cancel"),
+		),
+	)
+	if _, err := h.bot.Send(preview); err != nil {
+		text := h.loc.Get(lang, "text_preview_failed", localization.Args{"Error": html.EscapeString(err.Error())})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+		return
+	}
+
+	session.TextValue = value
+	session.State = "awaiting_text_confirm"
+}
+
+func (h *AdminHandler) sendTextInvalid(adminID int64, lang string, err error) {
+	reason := err.Error()
+	if err == localization.ErrUnknownKey || err == localization.ErrUnknownLanguage {
+		reason = h.loc.Get(lang, "text_invalid_target")
+	}
+
+	text := h.loc.Get(lang, "text_invalid", localization.Args{"Reason": html.EscapeString(reason)})
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleTextConfirm(query *tgbotapi.CallbackQuery, lang string, confirmed bool) {
+	adminID := query.From.ID
+
+	session, exists := h.sessions[adminID]
+	if !exists || session.State != "awaiting_text_confirm" {
+		return
+	}
+
+	// Убираем кнопки, чтобы текст нельзя было сохранить повторно
+	h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(adminID, query.Message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+
+	// Очищаем сессию
+	delete(h.sessions, adminID)
+
+	if !confirmed {
+		text := h.loc.Get(lang, "cancel_operation")
+		msg := tgbotapi.NewMessage(adminID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	// Файлы переводов могли перечитаться, пока администратор смотрел предпросмотр
+	if err := h.loc.CheckOverride(session.TextLang, session.TextKey, session.TextValue); err != nil {
+		h.sendTextInvalid(adminID, lang, err)
+		return
+	}
+
+	if err := h.db.SetTextOverride(session.TextLang, session.TextKey, session.TextValue, adminID); err != nil {
+		log.Printf("Error saving text %s.%s: %v", session.TextLang, session.TextKey, err)
+		return
+	}
+	h.loc.SetOverride(session.TextLang, session.TextKey, session.TextValue)
+
+	h.audit(adminID, models.AuditTextOverride, nil, map[string]interface{}{
+		"lang": session.TextLang,
+		"key":  session.TextKey,
+		"text": session.TextValue,
+	})
+
+	text := h.loc.Get(lang, "text_saved", localization.Args{"Lang": html.EscapeString(session.TextLang), "Key": html.EscapeString(session.TextKey)})
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
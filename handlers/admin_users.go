@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	cardHistoryLimit   = 5
+	cardReferralsLimit = 30
+	cardDateLayout     = "2006-01-02 15:04"
+)
+
+// HandleUserCommand обрабатывает /user <id|@username>
+func (h *AdminHandler) HandleUserCommand(update tgbotapi.Update) {
+	userID := update.Message.From.ID
+
+	if !h.isAdmin(userID) {
+		return
+	}
+
+	lang := h.adminLanguage(userID)
+
+	if !h.can(userID, models.PermManageUsers) {
+		h.sendNoPermission(userID, lang)
+		return
+	}
+
+	query := strings.TrimSpace(update.Message.CommandArguments())
+	if query == "" {
+		h.startUserSearch(userID, lang)
+		return
+	}
+
+	h.sendUserCardByQuery(userID, lang, query)
+}
+
+func (h *AdminHandler) adminLanguage(userID int64) string {
+	user, _ := h.db.GetUser(userID)
+	if user != nil {
+		return user.Language
+	}
+	return "ru"
+}
+
+func (h *AdminHandler) startUserSearch(adminID int64, lang string) {
+	h.sessions[adminID] = &models.UserSession{
+		State: "awaiting_user_search",
+	}
+
+	text := h.loc.Get(lang, "user_search_prompt")
+	msg := tgbotapi.NewMessage(adminID, text)
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleUserSearch(message *tgbotapi.Message, lang string) {
+	if h.sendUserCardByQuery(message.From.ID, lang, message.Text) {
+		delete(h.sessions, message.From.ID)
+	}
+}
+
+// lookupUser ищет пользователя по числовому ID или @username
+func (h *AdminHandler) lookupUser(query string) (*models.User, error) {
+	query = strings.TrimSpace(query)
+	if userID, err := strconv.ParseInt(query, 10, 64); err == nil {
+		return h.db.GetUser(userID)
+	}
+	return h.db.FindUserByUsername(strings.TrimPrefix(query, "@"))
+}
+
+func (h *AdminHandler) sendUserCardByQuery(adminID int64, lang, query string) bool {
+	user, err := h.lookupUser(query)
+	if err != nil {
+		log.Printf("Error looking up user %q: %v", query, err)
+		return false
+	}
+
+	if user == nil {
+		text := h.loc.Get(lang, "balance_user_not_found", localization.Args{"User": html.EscapeString(query)})
+		msg := tgbotapi.NewMessage(adminID, text)
+		h.bot.Send(msg)
+		return false
+	}
+
+	h.audit(adminID, models.AuditUserLookup, &user.UserID, map[string]interface{}{"query": query})
+	h.sendUserCard(adminID, lang, user)
+	return true
+}
+
+func (h *AdminHandler) sendUserCard(adminID int64, lang string, user *models.User) {
+	text, keyboard := h.buildUserCard(lang, user)
+
+	msg := tgbotapi.NewMessage(adminID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) buildUserCard(lang string, user *models.User) (string, tgbotapi.InlineKeyboardMarkup) {
+	none := h.loc.Get(lang, "user_card_none")
+
+	username := none
+	if user.Username != "" {
+		username = "@" + html.EscapeString(user.Username)
+	}
+
+	status := h.loc.Get(lang, "user_status_active")
+	if user.Banned {
+		status = h.loc.Get(lang, "user_status_banned")
+	} else if !user.IsActive {
+		status = h.loc.Get(lang, "user_status_blocked")
+	}
+	if user.Frozen {
+		status += ", " + h.loc.Get(lang, "user_status_frozen")
+	}
+	if user.NoRewards {
+		status += ", " + h.loc.Get(lang, "user_status_no_rewards")
+	}
+
+	referrer := none
+	if user.ReferredBy != nil {
+		referrer = fmt.Sprintf("<code>%d</code>", *user.ReferredBy)
+	}
+
+	lastActivity := none
+	if user.LastActivity != nil {
+		lastActivity = user.LastActivity.Format(cardDateLayout)
+	}
+
+	var text strings.Builder
+	text.WriteString(h.loc.Get(lang, "user_card", localization.Args{
+		"UserID":       user.UserID,
+		"Username":     username,
+		"Status":       status,
+		"Balance":      user.Balance,
+		"Language":     user.Language,
+		"Joined":       user.JoinDate.Format(cardDateLayout),
+		"Referrer":     referrer,
+		"Referrals":    len(user.Referrals),
+		"LastActivity": lastActivity,
+	}))
+
+	// История выводов
+	text.WriteString("\n\n" + h.loc.Get(lang, "user_card_withdrawals") + "\n")
+	withdrawals, err := h.db.GetWithdrawals(user.UserID, cardHistoryLimit)
+	if err != nil {
+		log.Printf("Error getting withdrawals for user %d: %v", user.UserID, err)
+	}
+	if len(withdrawals) == 0 {
+		text.WriteString(none)
+	}
+	for _, withdrawal := range withdrawals {
+		text.WriteString(h.loc.Get(lang, "user_card_withdrawal_line", localization.Args{
+			"ID":     withdrawal.ID,
+			"Date":   withdrawal.CreatedAt.Format(cardDateLayout),
+			"Amount": withdrawal.Amount,
+			"Status": withdrawal.Status,
+		}) + "\n")
+	}
+
+	// Последние операции по балансу
+	text.WriteString("\n" + h.loc.Get(lang, "user_card_ledger") + "\n")
+	entries, err := h.db.GetLedger(user.UserID, cardHistoryLimit)
+	if err != nil {
+		log.Printf("Error getting ledger for user %d: %v", user.UserID, err)
+	}
+	if len(entries) == 0 {
+		text.WriteString(none)
+	}
+	for _, entry := range entries {
+		text.WriteString(h.loc.Get(lang, "user_card_ledger_line", localization.Args{
+			"Date":         entry.CreatedAt.Format(cardDateLayout),
+			"Change":       signedAmount(h.loc, lang, entry.Amount),
+			"Kind":         entry.Kind,
+			"BalanceAfter": entry.BalanceAfter,
+		}) + "\n")
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_card_balance"), cardCallback("balance", user.UserID)),
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_card_message"), cardCallback("msg", user.UserID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			h.restrictionButton(lang, user.UserID, models.RestrictionBanned, user.Banned),
+			h.restrictionButton(lang, user.UserID, models.RestrictionFrozen, user.Frozen),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			h.restrictionButton(lang, user.UserID, models.RestrictionNoRewards, user.NoRewards),
+		),
+	}
+
+	var related []tgbotapi.InlineKeyboardButton
+	if user.ReferredBy != nil {
+		related = append(related, tgbotapi.NewInlineKeyboardButtonData(
+			h.loc.Get(lang, "btn_card_referrer"), cardCallback("open", *user.ReferredBy)))
+	}
+	if len(user.Referrals) > 0 {
+		related = append(related, tgbotapi.NewInlineKeyboardButtonData(
+			h.loc.Get(lang, "btn_card_referrals", localization.Args{"Total": len(user.Referrals)}), cardCallback("refs", user.UserID)))
+		related = append(related, tgbotapi.NewInlineKeyboardButtonData(
+			h.loc.Get(lang, "btn_card_tree"), cardCallback("tree", user.UserID)))
+	}
+	if len(related) > 0 {
+		rows = append(rows, related)
+	}
+
+	return text.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// restrictionButton переключает ограничение; название ограничения служит действием callback
+func (h *AdminHandler) restrictionButton(lang string, userID int64, restriction string, enabled bool) tgbotapi.InlineKeyboardButton {
+	state := "on"
+	if enabled {
+		state = "off"
+	}
+
+	return tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_card_"+restriction+"_"+state), cardCallback(restriction, userID))
+}
+
+func cardCallback(action string, userID int64) string {
+	return fmt.Sprintf("admin_card_%s_%d", action, userID)
+}
+
+// parseCardCallback разбирает callback вида admin_card_<action>_<user_id>
+func parseCardCallback(data string) (string, int64, bool) {
+	rest := strings.TrimPrefix(data, "admin_card_")
+	sep := strings.LastIndex(rest, "_")
+	if sep < 0 {
+		return "", 0, false
+	}
+
+	userID, err := strconv.ParseInt(rest[sep+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+
+	return rest[:sep], userID, true
+}
+
+func (h *AdminHandler) handleCardAction(query *tgbotapi.CallbackQuery, lang string) {
+	action, targetID, ok := parseCardCallback(query.Data)
+	if !ok {
+		return
+	}
+
+	target, err := h.db.GetUser(targetID)
+	if err != nil || target == nil {
+		text := h.loc.Get(lang, "balance_user_not_found", localization.Args{"User": targetID})
+		msg := tgbotapi.NewMessage(query.From.ID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	switch action {
+	case "open":
+		h.audit(query.From.ID, models.AuditUserLookup, &target.UserID, nil)
+		h.sendUserCard(query.From.ID, lang, target)
+	case "balance":
+		h.sessions[query.From.ID] = &models.UserSession{
+			State:                 "awaiting_balance_amount",
+			AwaitingBalanceUserID: target.UserID,
+		}
+
+		text := h.loc.Get(lang, "balance_prompt_amount", localization.Args{"UserID": target.UserID, "Balance": target.Balance})
+		msg := tgbotapi.NewMessage(query.From.ID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+	case models.RestrictionBanned, models.RestrictionFrozen, models.RestrictionNoRewards:
+		restriction := action
+
+		var flag *bool
+		switch restriction {
+		case models.RestrictionBanned:
+			flag = &target.Banned
+		case models.RestrictionFrozen:
+			flag = &target.Frozen
+		case models.RestrictionNoRewards:
+			flag = &target.NoRewards
+		}
+
+		if err := h.db.SetUserRestriction(target.UserID, restriction, !*flag); err != nil {
+			log.Printf("Error changing %s for user %d: %v", restriction, target.UserID, err)
+			return
+		}
+		*flag = !*flag
+
+		h.audit(query.From.ID, models.AuditUserRestrict, &target.UserID, map[string]interface{}{
+			"restriction": restriction,
+			"enabled":     *flag,
+		})
+
+		// Обновляем карточку на месте
+		text, keyboard := h.buildUserCard(lang, target)
+		edit := tgbotapi.NewEditMessageTextAndMarkup(query.From.ID, query.Message.MessageID, text, keyboard)
+		edit.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(edit)
+	case "msg":
+		h.sessions[query.From.ID] = &models.UserSession{
+			State:                 "awaiting_direct_message",
+			AwaitingMessageUserID: target.UserID,
+		}
+
+		text := h.loc.Get(lang, "direct_message_prompt", localization.Args{"UserID": target.UserID})
+		msg := tgbotapi.NewMessage(query.From.ID, text)
+		h.bot.Send(msg)
+	case "refs":
+		h.sendReferralList(query.From.ID, lang, target)
+	case "tree":
+		h.audit(query.From.ID, models.AuditUserLookup, &target.UserID, map[string]interface{}{"view": "tree"})
+		h.sendDownline(query.From.ID, lang, target)
+	}
+}
+
+func (h *AdminHandler) sendReferralList(adminID int64, lang string, user *models.User) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+
+	for i, referralID := range user.Referrals {
+		if i == cardReferralsLimit {
+			break
+		}
+
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			strconv.FormatInt(referralID, 10), cardCallback("open", referralID)))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	text := h.loc.Get(lang, "user_card_referrals_title", localization.Args{"UserID": user.UserID, "Total": len(user.Referrals)})
+	msg := tgbotapi.NewMessage(adminID, text)
+	if len(rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	h.bot.Send(msg)
+}
+
+func (h *AdminHandler) handleDirectMessage(message *tgbotapi.Message, lang string, session *models.UserSession) {
+	targetID := session.AwaitingMessageUserID
+
+	// Копируем сообщение целиком, чтобы сохранить фото, форматирование и т.д.
+	copyMsg := tgbotapi.NewCopyMessage(targetID, message.Chat.ID, message.MessageID)
+	_, err := h.bot.Request(copyMsg)
+
+	content := message.Text
+	if content == "" {
+		content = message.Caption
+	}
+	h.audit(message.From.ID, models.AuditDirectMessage, &targetID, map[string]interface{}{
+		"text":      truncate(content, 500),
+		"delivered": err == nil,
+	})
+
+	if err != nil {
+		if isBlockedError(err) {
+			if err := h.db.SetUserActive(targetID, false); err != nil {
+				log.Printf("Error marking user %d inactive: %v", targetID, err)
+			}
+		}
+
+		text := h.loc.Get(lang, "direct_message_failed", localization.Args{"UserID": targetID})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+	} else {
+		text := h.loc.Get(lang, "direct_message_sent", localization.Args{"UserID": targetID})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+	}
+
+	// Очищаем сессию
+	delete(h.sessions, message.From.ID)
+}
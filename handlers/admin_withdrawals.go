@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// withdrawalKeyboard - кнопки под уведомлением о новой заявке на вывод
+func withdrawalKeyboard(loc *localization.Localization, lang string, withdrawalID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.Get(lang, "btn_withdrawal_approve"), fmt.Sprintf("admin_wd_approve_%d", withdrawalID)),
+			tgbotapi.NewInlineKeyboardButtonData(loc.Get(lang, "btn_withdrawal_reject"), fmt.Sprintf("admin_wd_reject_%d", withdrawalID)),
+		),
+	)
+}
+
+func (h *AdminHandler) handleWithdrawalDecision(query *tgbotapi.CallbackQuery, lang string) {
+	action, rest, found := strings.Cut(strings.TrimPrefix(query.Data, "admin_wd_"), "_")
+	if !found {
+		return
+	}
+
+	withdrawalID, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return
+	}
+
+	withdrawal, err := h.db.GetWithdrawal(withdrawalID)
+	if err != nil || withdrawal == nil {
+		log.Printf("Error getting withdrawal %d: %v", withdrawalID, err)
+		return
+	}
+
+	adminID := query.From.ID
+	var auditAction, userKey string
+
+	switch action {
+	case "approve":
+		err = h.db.ApproveWithdrawal(withdrawalID, adminID)
+		auditAction, userKey = models.AuditWithdrawalApprove, "withdrawal_approved_user"
+	case "reject":
+		err = h.db.RejectWithdrawal(withdrawalID, adminID)
+		auditAction, userKey = models.AuditWithdrawalReject, "withdrawal_rejected_user"
+	default:
+		return
+	}
+
+	if err != nil {
+		if err == database.ErrWithdrawalNotPending {
+			text := h.loc.Get(lang, "withdrawal_already_processed", localization.Args{"ID": withdrawalID})
+			msg := tgbotapi.NewMessage(adminID, text)
+			h.bot.Send(msg)
+			return
+		}
+		log.Printf("Error processing withdrawal %d: %v", withdrawalID, err)
+		return
+	}
+
+	h.audit(adminID, auditAction, &withdrawal.UserID, map[string]interface{}{
+		"withdrawal_id": withdrawal.ID,
+		"amount":        withdrawal.Amount,
+		"wallet":        withdrawal.Wallet,
+	})
+
+	// Убираем кнопки и отмечаем решение под уведомлением
+	resultText := h.loc.Get(lang, "withdrawal_processed_admin", localization.Args{
+		"ID":      withdrawalID,
+		"Status":  h.loc.Get(lang, "withdrawal_status_"+action),
+		"AdminID": adminID,
+	})
+	h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(adminID, query.Message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+	h.bot.Send(tgbotapi.NewMessage(adminID, resultText))
+
+	// Уведомляем пользователя на его языке
+	userLang := "ru"
+	if user, _ := h.db.GetUser(withdrawal.UserID); user != nil {
+		userLang = user.Language
+	}
+	text := h.loc.Get(userLang, userKey, localization.Args{"Amount": withdrawal.Amount, "Wallet": withdrawal.Wallet})
+	msg := tgbotapi.NewMessage(withdrawal.UserID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
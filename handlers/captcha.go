@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"telegram-bot/captcha"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// requireCaptcha выдает задание и возвращает true, если пользователь еще не прошел капчу
+func (h *UserHandler) requireCaptcha(user *models.User) bool {
+	if user.CaptchaPassed {
+		return false
+	}
+
+	// Капчу отключили после регистрации пользователя
+	if h.config.CaptchaMode == captcha.ModeOff {
+		if err := h.db.MarkCaptchaPassed(user.UserID); err != nil {
+			log.Printf("Error marking captcha passed for user %d: %v", user.UserID, err)
+		}
+		user.CaptchaPassed = true
+		h.claimPendingVoucher(user.UserID)
+		return false
+	}
+
+	h.sendCaptcha(user)
+	return true
+}
+
+func (h *UserHandler) sendCaptcha(user *models.User) {
+	until, err := h.db.CaptchaLockedUntil(user.UserID)
+	if err != nil {
+		log.Printf("Error checking captcha lockout for user %d: %v", user.UserID, err)
+		return
+	}
+	if until != nil {
+		text := h.loc.Get(user.Language, "captcha_locked", localization.Args{"Until": until.Format(cardDateLayout)})
+		msg := tgbotapi.NewMessage(user.UserID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	challenge, err := captcha.New(h.config.CaptchaMode)
+	if err != nil {
+		log.Printf("Error generating captcha for user %d: %v", user.UserID, err)
+		return
+	}
+
+	if err := h.db.SaveCaptcha(user.UserID, challenge.Answer); err != nil {
+		log.Printf("Error saving captcha for user %d: %v", user.UserID, err)
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, option := range challenge.Options {
+		if i%captcha.OptionsPerRow == 0 {
+			rows = append(rows, nil)
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], tgbotapi.NewInlineKeyboardButtonData(option, "captcha_"+option))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	text := h.loc.Get(user.Language, challenge.PromptKey)
+	if challenge.PromptArg != "" {
+		text = h.loc.Get(user.Language, challenge.PromptKey, localization.Args{"Task": challenge.PromptArg})
+	}
+
+	if challenge.Image != nil {
+		photo := tgbotapi.NewPhoto(user.UserID, tgbotapi.FileBytes{Name: "captcha.png", Bytes: challenge.Image})
+		photo.Caption = text
+		photo.ReplyMarkup = keyboard
+		h.bot.Send(photo)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(user.UserID, text)
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+func (h *UserHandler) handleCaptchaCallback(query *tgbotapi.CallbackQuery, user *models.User) {
+	if user.CaptchaPassed {
+		return
+	}
+
+	// Каждое задание принимает только один ответ
+	h.clearInlineKeyboard(query)
+
+	answer := strings.TrimPrefix(query.Data, "captcha_")
+	result, until, err := h.db.CheckCaptcha(user.UserID, answer, h.config.CaptchaMaxAttempts, h.config.CaptchaLockout)
+	if err != nil {
+		log.Printf("Error checking captcha for user %d: %v", user.UserID, err)
+		return
+	}
+
+	switch result {
+	case database.CaptchaPassed:
+		text := h.loc.Get(user.Language, "captcha_passed")
+		msg := tgbotapi.NewMessage(user.UserID, text)
+		h.bot.Send(msg)
+
+		h.onboard(user.UserID)
+		h.claimPendingVoucher(user.UserID)
+
+		h.checkReferral(user.UserID)
+		if !h.requireSubscription(user) {
+			h.sendUserMenu(user.UserID, user.Language)
+		}
+	case database.CaptchaWrong:
+		text := h.loc.Get(user.Language, "captcha_wrong")
+		msg := tgbotapi.NewMessage(user.UserID, text)
+		h.bot.Send(msg)
+
+		h.sendCaptcha(user)
+	case database.CaptchaLocked:
+		text := h.loc.Get(user.Language, "captcha_locked", localization.Args{"Until": until.Format(cardDateLayout)})
+		msg := tgbotapi.NewMessage(user.UserID, text)
+		h.bot.Send(msg)
+	}
+}
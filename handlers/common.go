@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"telegram-bot/localization"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// isBlockedError определяет ошибки Telegram, после которых писать пользователю бессмысленно
+func isBlockedError(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 403 {
+		return false
+	}
+
+	message := strings.ToLower(apiErr.Message)
+	return strings.Contains(message, "bot was blocked by the user") ||
+		strings.Contains(message, "user is deactivated")
+}
+
+// truncate обрезает строку до limit символов, не разрывая многобайтовые руны
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit]) + "…"
+}
+
+// signedAmount форматирует изменение баланса со знаком: +1 234,50 или -0,14
+func signedAmount(loc *localization.Localization, lang string, amount float64) string {
+	if amount > 0 {
+		return "+" + loc.FormatAmount(lang, amount)
+	}
+	return loc.FormatAmount(lang, amount)
+}
+
+// codeLabel выводит ID пользователя моноширинным шрифтом для HTML-сообщений
+func codeLabel(userID int64) string {
+	return fmt.Sprintf("<code>%d</code>", userID)
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *UserHandler) handleGift(query *tgbotapi.CallbackQuery, user *models.User) {
+	// Замороженный пользователь не должен выводить средства через подарки
+	if user.Frozen {
+		text := h.loc.Get(user.Language, "withdraw_frozen")
+		msg := tgbotapi.NewMessage(query.From.ID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	// Предлагаем перевести напрямую или создать ссылку-ваучер
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_gift_direct"), "gift_mode_direct"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_gift_voucher"), "gift_mode_voucher"),
+		),
+	)
+
+	text := h.loc.Get(user.Language, "gift_choose_mode")
+	msg := tgbotapi.NewMessage(query.From.ID, text)
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+func (h *UserHandler) startDirectGift(user *models.User) {
+	h.sessions[user.UserID] = &models.UserSession{
+		State: "awaiting_gift_recipient",
+	}
+
+	text := h.loc.Get(user.Language, "gift_prompt_recipient")
+	msg := tgbotapi.NewMessage(user.UserID, text)
+	h.bot.Send(msg)
+}
+
+// resolveGiftRecipient определяет получателя по пересланному сообщению, ID или @username
+func (h *UserHandler) resolveGiftRecipient(message *tgbotapi.Message) (*models.User, error) {
+	if message.ForwardFrom != nil {
+		return h.db.GetUser(message.ForwardFrom.ID)
+	}
+
+	input := strings.TrimSpace(message.Text)
+	if userID, err := strconv.ParseInt(input, 10, 64); err == nil {
+		return h.db.GetUser(userID)
+	}
+	if strings.HasPrefix(input, "@") {
+		return h.db.FindUserByUsername(strings.TrimPrefix(input, "@"))
+	}
+	return nil, nil
+}
+
+// readGiftRecipient находит получателя подарка и сообщает пользователю, если он не подходит
+func (h *UserHandler) readGiftRecipient(message *tgbotapi.Message, user *models.User) (*models.User, bool) {
+	// Пользователь скрыл аккаунт при пересылке - узнать ID невозможно
+	if message.ForwardFrom == nil && message.ForwardSenderName != "" {
+		text := h.loc.Get(user.Language, "gift_forward_hidden")
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		return nil, false
+	}
+
+	recipient, err := h.resolveGiftRecipient(message)
+	if err != nil {
+		log.Printf("Error resolving gift recipient: %v", err)
+		return nil, false
+	}
+
+	if recipient == nil || recipient.Banned {
+		text := h.loc.Get(user.Language, "gift_recipient_not_found")
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		return nil, false
+	}
+
+	if recipient.UserID == user.UserID {
+		text := h.loc.Get(user.Language, "gift_self")
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		return nil, false
+	}
+
+	return recipient, true
+}
+
+func (h *UserHandler) handleGiftRecipient(message *tgbotapi.Message, user *models.User, session *models.UserSession) {
+	recipient, ok := h.readGiftRecipient(message, user)
+	if !ok {
+		return
+	}
+
+	session.GiftRecipientID = recipient.UserID
+	session.State = "awaiting_gift_amount"
+
+	text := h.loc.Get(user.Language, "gift_prompt_amount",
+		localization.Args{"Available": h.giftAvailable(user), "Min": h.config.GiftMinAmount})
+	msg := tgbotapi.NewMessage(message.From.ID, text)
+	h.bot.Send(msg)
+}
+
+// giftAvailable возвращает сумму, которую пользователь может подарить с учетом лимитов
+func (h *UserHandler) giftAvailable(user *models.User) float64 {
+	available := user.Balance - h.config.GiftMinBalance
+
+	if h.config.GiftDailyLimit > 0 {
+		sent, err := h.db.GiftedToday(user.UserID)
+		if err != nil {
+			log.Printf("Error getting gifted amount for user %d: %v", user.UserID, err)
+		}
+		available = math.Min(available, h.config.GiftDailyLimit-sent)
+	}
+
+	return math.Max(available, 0)
+}
+
+// readGiftAmount проверяет введенную сумму подарка и сообщает пользователю об ошибке
+func (h *UserHandler) readGiftAmount(message *tgbotapi.Message, user *models.User) (float64, bool) {
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(message.Text), ",", "."), 64)
+	if err != nil || math.IsNaN(amount) || amount < h.config.GiftMinAmount {
+		text := h.loc.Get(user.Language, "gift_invalid_amount", localization.Args{"Min": h.config.GiftMinAmount})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		return 0, false
+	}
+
+	if available := h.giftAvailable(user); amount > available {
+		text := h.loc.Get(user.Language, "gift_amount_too_large", localization.Args{"Available": available})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		return 0, false
+	}
+
+	return amount, true
+}
+
+func (h *UserHandler) handleGiftAmount(message *tgbotapi.Message, user *models.User, session *models.UserSession) {
+	amount, ok := h.readGiftAmount(message, user)
+	if !ok {
+		return
+	}
+
+	session.GiftAmount = amount
+	session.State = "awaiting_gift_note"
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_gift_skip_note"), "gift_skip_note"),
+		),
+	)
+
+	text := h.loc.Get(user.Language, "gift_prompt_note")
+	msg := tgbotapi.NewMessage(message.From.ID, text)
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+func (h *UserHandler) handleGiftNote(message *tgbotapi.Message, user *models.User, session *models.UserSession) {
+	session.GiftNote = truncate(strings.TrimSpace(message.Text), 200)
+	h.sendGiftConfirmation(user, session)
+}
+
+func (h *UserHandler) sendGiftConfirmation(user *models.User, session *models.UserSession) {
+	session.State = "awaiting_gift_confirm"
+
+	note := h.loc.Get(user.Language, "user_card_none")
+	if session.GiftNote != "" {
+		note = html.EscapeString(session.GiftNote)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_confirm"), "gift_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_cancel"), "gift_cancel"),
+		),
+	)
+
+	text := h.loc.Get(user.Language, "gift_confirm", localization.Args{
+		"Amount":    session.GiftAmount,
+		"Recipient": h.userLabel(session.GiftRecipientID),
+		"Note":      note,
+	})
+	msg := tgbotapi.NewMessage(user.UserID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+// userLabel показывает пользователя как @username, а если его нет - по ID
+func (h *UserHandler) userLabel(userID int64) string {
+	if user, _ := h.db.GetUser(userID); user != nil && user.Username != "" {
+		return "@" + html.EscapeString(user.Username)
+	}
+	return fmt.Sprintf("<code>%d</code>", userID)
+}
+
+func (h *UserHandler) handleGiftCallback(query *tgbotapi.CallbackQuery, user *models.User) {
+	switch query.Data {
+	case "gift_mode_direct":
+		h.startDirectGift(user)
+		return
+	case "gift_mode_voucher":
+		h.startVoucher(user)
+		return
+	}
+
+	session, exists := h.sessions[user.UserID]
+	if !exists {
+		return
+	}
+
+	switch {
+	case query.Data == "gift_skip_note" && session.State == "awaiting_gift_note":
+		session.GiftNote = ""
+		h.sendGiftConfirmation(user, session)
+	case query.Data == "gift_confirm" && session.State == "awaiting_gift_confirm":
+		h.clearInlineKeyboard(query)
+		delete(h.sessions, user.UserID)
+		h.completeGift(user, session)
+	case query.Data == "gift_voucher_anyone" && session.State == "awaiting_voucher_recipient":
+		session.GiftRecipientID = 0
+		h.sendVoucherConfirmation(user, session)
+	case query.Data == "gift_voucher_confirm" && session.State == "awaiting_voucher_confirm":
+		h.clearInlineKeyboard(query)
+		delete(h.sessions, user.UserID)
+		h.completeVoucher(user, session)
+	case query.Data == "gift_cancel" && (session.State == "awaiting_gift_confirm" || session.State == "awaiting_voucher_confirm"):
+		h.clearInlineKeyboard(query)
+		h.handleCancel(user.UserID)
+	}
+}
+
+func (h *UserHandler) clearInlineKeyboard(query *tgbotapi.CallbackQuery) {
+	h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(query.From.ID, query.Message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+}
+
+func (h *UserHandler) completeGift(sender *models.User, session *models.UserSession) {
+	limits := database.GiftLimits{
+		DailyLimit: h.config.GiftDailyLimit,
+		MinBalance: h.config.GiftMinBalance,
+	}
+
+	err := h.db.TransferGift(sender.UserID, session.GiftRecipientID, session.GiftAmount, session.GiftNote, limits)
+	if err != nil {
+		var key string
+		switch err {
+		case database.ErrInsufficientFunds, database.ErrGiftMinBalance, database.ErrGiftDailyLimit:
+			key = "gift_limit_reached"
+		case database.ErrGiftSenderRestricted:
+			key = "withdraw_frozen"
+		case database.ErrGiftRecipientRestricted:
+			key = "gift_recipient_not_found"
+		default:
+			log.Printf("Error transferring gift from %d to %d: %v", sender.UserID, session.GiftRecipientID, err)
+			key = "gift_failed"
+		}
+
+		text := h.loc.Get(sender.Language, key)
+		msg := tgbotapi.NewMessage(sender.UserID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	// Уведомляем отправителя
+	text := h.loc.Get(sender.Language, "gift_sent",
+		localization.Args{"Amount": session.GiftAmount, "Recipient": h.userLabel(session.GiftRecipientID)})
+	msg := tgbotapi.NewMessage(sender.UserID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+
+	// Уведомляем получателя на его языке
+	recipient, err := h.db.GetUser(session.GiftRecipientID)
+	if err != nil || recipient == nil {
+		return
+	}
+
+	text = h.loc.Get(recipient.Language, "gift_received",
+		localization.Args{"Amount": session.GiftAmount, "Sender": h.userLabel(sender.UserID)})
+	if session.GiftNote != "" {
+		text += "\n\n" + h.loc.Get(recipient.Language, "gift_received_note", localization.Args{"Note": html.EscapeString(session.GiftNote)})
+	}
+	msg = tgbotapi.NewMessage(recipient.UserID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	if _, err := h.bot.Send(msg); isBlockedError(err) {
+		h.db.SetUserActive(recipient.UserID, false)
+	}
+}
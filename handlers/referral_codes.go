@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	maxReferralCodes    = 10
+	maxReferralCodeName = 32
+)
+
+func referralCodeLink(botName, code string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", botName, code)
+}
+
+// referralLink возвращает основную реферальную ссылку пользователя
+func (h *UserHandler) referralLink(userID int64) string {
+	code, err := h.db.GetDefaultReferralCode(userID)
+	if err != nil {
+		log.Printf("Error getting referral code for user %d: %v", userID, err)
+		return ""
+	}
+	return referralCodeLink(h.bot.Self.UserName, code)
+}
+
+func (h *UserHandler) sendReferralCodes(user *models.User) {
+	// Основной код создается при первом показе профиля, но гарантируем его наличие
+	if _, err := h.db.GetDefaultReferralCode(user.UserID); err != nil {
+		log.Printf("Error getting referral code for user %d: %v", user.UserID, err)
+		return
+	}
+
+	codes, err := h.db.ListReferralCodes(user.UserID)
+	if err != nil {
+		log.Printf("Error listing referral codes for user %d: %v", user.UserID, err)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(h.loc.Get(user.Language, "ref_codes_title"))
+	for _, code := range codes {
+		name := h.loc.Get(user.Language, "ref_code_default")
+		if code.Name != "" {
+			name = html.EscapeString(code.Name)
+		}
+		b.WriteString("\n\n")
+		b.WriteString(h.loc.Get(user.Language, "ref_code_line", localization.Args{
+			"Name":    name,
+			"Invites": code.Invites,
+			"Link":    referralCodeLink(h.bot.Self.UserName, code.Code),
+		}))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_ref_code_new"), "user_ref_code_new"),
+		),
+	)
+
+	msg := tgbotapi.NewMessage(user.UserID, b.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	msg.DisableWebPagePreview = true
+	h.bot.Send(msg)
+}
+
+func (h *UserHandler) handleNewReferralCode(user *models.User) {
+	h.sessions[user.UserID] = &models.UserSession{
+		State: "awaiting_ref_code_name",
+	}
+
+	text := h.loc.Get(user.Language, "ref_code_prompt_name", localization.Args{"Max": maxReferralCodeName})
+	msg := tgbotapi.NewMessage(user.UserID, text)
+	h.bot.Send(msg)
+}
+
+func (h *UserHandler) handleReferralCodeName(message *tgbotapi.Message, user *models.User) {
+	name := strings.TrimSpace(message.Text)
+	if name == "" || utf8.RuneCountInString(name) > maxReferralCodeName {
+		text := h.loc.Get(user.Language, "ref_code_invalid_name", localization.Args{"Max": maxReferralCodeName})
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	delete(h.sessions, user.UserID)
+
+	code, err := h.db.CreateReferralCode(user.UserID, name, maxReferralCodes)
+	if err != nil {
+		var text string
+		if err == database.ErrReferralCodeLimit {
+			text = h.loc.Get(user.Language, "ref_code_limit", localization.Args{"Max": maxReferralCodes})
+		} else {
+			log.Printf("Error creating referral code for user %d: %v", user.UserID, err)
+			text = h.loc.Get(user.Language, "ref_code_failed")
+		}
+
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	text := h.loc.Get(user.Language, "ref_code_created",
+		localization.Args{"Name": html.EscapeString(code.Name), "Link": referralCodeLink(h.bot.Self.UserName, code.Code)})
+	msg := tgbotapi.NewMessage(message.From.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"image/color"
+	"log"
+	"strconv"
+	"strings"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+	"telegram-bot/render"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	referralsPageSize = 10
+
+	downlineMaxDepth = 5
+	downlineMaxNodes = 200
+	// Текст дерева должен поместиться в одно сообщение Telegram
+	downlineTextLimit = 3500
+)
+
+// Цвет рамки узла на изображении дерева по статусу реферала
+var downlineColors = map[string]color.Color{
+	models.ReferralConfirmed: color.RGBA{0x2E, 0x9E, 0x5B, 0xFF},
+	models.ReferralPending:   color.RGBA{0xD9, 0xA4, 0x1E, 0xFF},
+	models.ReferralHeld:      color.RGBA{0xE0, 0x6C, 0x1F, 0xFF},
+	models.ReferralRejected:  color.RGBA{0xC8, 0x32, 0x32, 0xFF},
+}
+
+var downlineIcons = map[string]string{
+	models.ReferralConfirmed: "✅",
+	models.ReferralPending:   "⏳",
+	models.ReferralHeld:      "🚩",
+	models.ReferralRejected:  "❌",
+}
+
+func referralsPageCallback(page int) string {
+	return fmt.Sprintf("user_refs_%d", page)
+}
+
+func (h *UserHandler) handleReferralsPage(query *tgbotapi.CallbackQuery, user *models.User) {
+	page, err := strconv.Atoi(strings.TrimPrefix(query.Data, "user_refs_"))
+	if err != nil || page < 0 {
+		return
+	}
+
+	entries, total, err := h.db.ListReferrals(user.UserID, referralsPageSize, page*referralsPageSize)
+	if err != nil {
+		log.Printf("Error listing referrals of %d: %v", user.UserID, err)
+		return
+	}
+
+	pages := (total + referralsPageSize - 1) / referralsPageSize
+	if pages == 0 {
+		pages = 1
+	}
+
+	lang := user.Language
+	var text strings.Builder
+	text.WriteString(h.loc.Get(lang, "my_referrals_title", localization.Args{"Total": total, "Page": page + 1, "Pages": pages}) + "\n\n")
+	if len(entries) == 0 {
+		text.WriteString(h.loc.Get(lang, "my_referrals_empty"))
+	}
+	for i, entry := range entries {
+		name := codeLabel(entry.UserID)
+		if entry.Username != "" {
+			name = "@" + html.EscapeString(entry.Username)
+		}
+		text.WriteString(h.loc.Get(lang, "my_referrals_line", localization.Args{
+			"N":      page*referralsPageSize + i + 1,
+			"Name":   name,
+			"Date":   entry.JoinedAt.Format(cardDateLayout),
+			"Status": h.loc.Get(lang, "ref_status_"+entry.Status),
+			"Earned": entry.Earned,
+		}) + "\n")
+	}
+
+	var navigation []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("⬅️", referralsPageCallback(page-1)))
+	}
+	if page+1 < pages {
+		navigation = append(navigation, tgbotapi.NewInlineKeyboardButtonData("➡️", referralsPageCallback(page+1)))
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{}
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_back"), "main_menu"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	edit := tgbotapi.NewEditMessageTextAndMarkup(query.From.ID, query.Message.MessageID, text.String(), keyboard)
+	edit.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(edit)
+}
+
+// HandleTreeCommand обрабатывает /tree <id|@username>
+func (h *AdminHandler) HandleTreeCommand(update tgbotapi.Update) {
+	adminID := update.Message.From.ID
+
+	if !h.isAdmin(adminID) {
+		return
+	}
+
+	lang := h.adminLanguage(adminID)
+
+	if !h.can(adminID, models.PermManageUsers) {
+		h.sendNoPermission(adminID, lang)
+		return
+	}
+
+	query := strings.TrimSpace(update.Message.CommandArguments())
+	if query == "" {
+		text := h.loc.Get(lang, "tree_usage")
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+		return
+	}
+
+	user, err := h.lookupUser(query)
+	if err != nil {
+		log.Printf("Error looking up user %q: %v", query, err)
+		return
+	}
+	if user == nil {
+		text := h.loc.Get(lang, "balance_user_not_found", localization.Args{"User": html.EscapeString(query)})
+		msg := tgbotapi.NewMessage(adminID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	h.audit(adminID, models.AuditUserLookup, &user.UserID, map[string]interface{}{"query": query, "view": "tree"})
+	h.sendDownline(adminID, lang, user)
+}
+
+// sendDownline отправляет дерево приглашений пользователя текстом и изображением
+func (h *AdminHandler) sendDownline(adminID int64, lang string, user *models.User) {
+	root, nodes, truncated, err := h.db.Downline(user.UserID, downlineMaxDepth, downlineMaxNodes)
+	if err != nil || root == nil {
+		log.Printf("Error building downline of %d: %v", user.UserID, err)
+		return
+	}
+
+	var text strings.Builder
+	text.WriteString(h.loc.Get(lang, "tree_title", localization.Args{"UserID": user.UserID, "Count": nodes}) + "\n\n")
+	text.WriteString(downlineText(h.loc, lang, root))
+	if truncated {
+		text.WriteString("\n" + h.loc.Get(lang, "tree_truncated", localization.Args{"Levels": downlineMaxDepth, "Users": downlineMaxNodes}))
+	}
+
+	msg := tgbotapi.NewMessage(adminID, text.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+
+	if nodes == 0 {
+		return
+	}
+
+	img := render.Tree(downlineImageNode(h.loc, lang, root), render.DefaultTreeOptions)
+	data, err := render.PNG(img)
+	if err != nil {
+		log.Printf("Error rendering downline of %d: %v", user.UserID, err)
+		return
+	}
+
+	file := tgbotapi.FileBytes{Name: fmt.Sprintf("tree_%d.png", user.UserID), Bytes: data}
+	// Telegram не принимает фото больше 10000 пикселей по сумме сторон, такие деревья отправляем файлом
+	bounds := img.Bounds()
+	if bounds.Dx()+bounds.Dy() > 10000 || bounds.Dx() > 20*bounds.Dy() {
+		h.bot.Send(tgbotapi.NewDocument(adminID, file))
+		return
+	}
+	h.bot.Send(tgbotapi.NewPhoto(adminID, file))
+}
+
+// downlineText выводит дерево псевдографикой в блоке <pre>
+func downlineText(loc *localization.Localization, lang string, root *models.ReferralNode) string {
+	var b strings.Builder
+	b.WriteString("<pre>")
+	b.WriteString(downlineLabel(loc, lang, root))
+
+	var walk func(node *models.ReferralNode, prefix string) bool
+	walk = func(node *models.ReferralNode, prefix string) bool {
+		for i, child := range node.Children {
+			if b.Len() > downlineTextLimit {
+				b.WriteString("\n" + prefix + "…")
+				return false
+			}
+
+			branch, indent := "├─ ", "│  "
+			if i == len(node.Children)-1 {
+				branch, indent = "└─ ", "   "
+			}
+			b.WriteString("\n" + prefix + branch + downlineLabel(loc, lang, child))
+			if !walk(child, prefix+indent) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(root, "")
+
+	b.WriteString("</pre>")
+	return b.String()
+}
+
+func downlineLabel(loc *localization.Localization, lang string, node *models.ReferralNode) string {
+	label := strconv.FormatInt(node.UserID, 10)
+	if node.Username != "" {
+		label += " @" + html.EscapeString(node.Username)
+	}
+	if icon, ok := downlineIcons[node.Status]; ok {
+		label += " " + icon
+	}
+	if node.Earned > 0 {
+		label += " " + signedAmount(loc, lang, node.Earned)
+	}
+	return label
+}
+
+// downlineImageNode переводит дерево рефералов в узлы для render.Tree
+func downlineImageNode(loc *localization.Localization, lang string, node *models.ReferralNode) *render.TreeNode {
+	lines := []string{strconv.FormatInt(node.UserID, 10)}
+	if node.Username != "" {
+		lines = append(lines, "@"+truncate(node.Username, 14))
+	}
+	if node.Earned > 0 {
+		lines = append(lines, signedAmount(loc, lang, node.Earned))
+	}
+
+	imageNode := &render.TreeNode{Lines: lines, Color: downlineColors[node.Status]}
+	for _, child := range node.Children {
+		imageNode.Children = append(imageNode.Children, downlineImageNode(loc, lang, child))
+	}
+	return imageNode
+}
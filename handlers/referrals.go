@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"telegram-bot/config"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// checkReferral подтверждает реферала пользователя, если выполнены все условия
+func (h *UserHandler) checkReferral(userID int64) {
+	pending, err := h.db.HasPendingReferral(userID)
+	if err != nil || !pending {
+		return
+	}
+
+	// Подписку проверяем через Telegram, остальные условия - в базе
+	var conditions []string
+	for _, condition := range h.config.ReferralConditions {
+		if condition != models.ReferralCondChannel {
+			conditions = append(conditions, condition)
+		} else if !h.subscribed(userID) {
+			return
+		}
+	}
+
+	rewards, held, err := h.db.ConfirmReferral(userID, database.ReferralPolicy{
+		Conditions: conditions,
+		ActiveDays: h.config.ReferralActiveDays,
+		Rewards:    referralRewards(h.db, h.config, userID),
+		Screen:     h.fraud.Evaluate,
+	})
+	if err != nil {
+		log.Printf("Error confirming referral %d: %v", userID, err)
+		return
+	}
+
+	if held != nil {
+		h.notifyHeldReferral(held)
+		return
+	}
+
+	// Уведомляем рефереров всех уровней
+	notifyReferralRewards(h.bot, h.db, h.loc, rewards)
+
+	// Подтверждение разблокирует бонус приглашенного и может разблокировать бонус реферера
+	unlockWelcomeBonus(h.bot, h.db, h.loc, h.config.WelcomeBonusUnlock, userID)
+	if user, _ := h.db.GetUser(userID); user != nil && user.ReferredBy != nil {
+		unlockWelcomeBonus(h.bot, h.db, h.loc, h.config.WelcomeBonusUnlock, *user.ReferredBy)
+	}
+}
+
+// referralRewards возвращает суммы наград по уровням за реферала с учетом его кампании
+func referralRewards(db *database.Database, cfg *config.Config, referredID int64) []float64 {
+	amount, err := db.CampaignRewardAmount(referredID)
+	if err != nil {
+		log.Printf("Error getting campaign reward for %d: %v", referredID, err)
+	}
+	if amount == nil {
+		return cfg.ReferralRewards()
+	}
+	return cfg.RewardsFor(*amount)
+}
+
+// notifyHeldReferral отправляет задержанного реферала на проверку администраторам
+func (h *UserHandler) notifyHeldReferral(held *models.HeldReferral) {
+	adminIDs, err := h.db.GetAdminIDsWithPermission(models.PermManageUsers)
+	if err != nil {
+		log.Printf("Error getting admins for held referral %d: %v", held.ReferredID, err)
+	}
+
+	for _, adminID := range adminIDs {
+		adminLang := "ru"
+		if admin, _ := h.db.GetUser(adminID); admin != nil {
+			adminLang = admin.Language
+		}
+
+		text := h.loc.Get(adminLang, "referral_held_admin", localization.Args{
+			"Referral": h.userLabel(held.ReferredID),
+			"Referrer": h.userLabel(held.ReferrerID),
+			"Score":    held.Score,
+			"Rules":    strings.Join(held.Reasons, ", "),
+		})
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyMarkup = heldReferralKeyboard(h.loc, adminLang, held.ReferredID)
+		h.bot.Send(msg)
+	}
+}
+
+// heldReferralKeyboard - кнопки решения по задержанному рефералу
+func heldReferralKeyboard(loc *localization.Localization, lang string, referredID int64) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(loc.Get(lang, "btn_referral_release"), fmt.Sprintf("admin_fraud_release_%d", referredID)),
+			tgbotapi.NewInlineKeyboardButtonData(loc.Get(lang, "btn_referral_reject"), fmt.Sprintf("admin_fraud_reject_%d", referredID)),
+		),
+	)
+}
+
+// notifyReferralRewards сообщает каждому рефереру цепочки о начисленной награде
+func notifyReferralRewards(bot *tgbotapi.BotAPI, db *database.Database, loc *localization.Localization, rewards []models.ReferralReward) {
+	for _, reward := range rewards {
+		referrer, _ := db.GetUser(reward.UserID)
+		if referrer == nil {
+			continue
+		}
+
+		var text string
+		if reward.Level == 1 {
+			text = loc.Get(referrer.Language, "new_referral_notification", localization.Args{"Amount": reward.Amount})
+		} else {
+			text = loc.Get(referrer.Language, "new_referral_level_notification",
+				localization.Args{"Level": reward.Level, "Amount": reward.Amount})
+		}
+		msg := tgbotapi.NewMessage(reward.UserID, text)
+		bot.Send(msg)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"telegram-bot/models"
+)
+
+// startPayload - разобранный аргумент команды /start из deep link
+type startPayload struct {
+	ReferrerID   *int64
+	ReferralCode string
+	VoucherCode  string
+	Campaign     *models.Campaign
+}
+
+// parseStartPayload понимает ссылки вида ?start=<code>, ?start=gift_<code>,
+// ?start=c_<campaign> и устаревшие числовые ?start=<referrer_id>
+func (h *UserHandler) parseStartPayload(userID int64, args string) startPayload {
+	var payload startPayload
+
+	args = strings.TrimSpace(args)
+	switch {
+	case args == "":
+	case strings.HasPrefix(args, "gift_"):
+		payload.VoucherCode = strings.TrimPrefix(args, "gift_")
+	case strings.HasPrefix(args, "c_"):
+		campaign, err := h.db.GetCampaign(strings.TrimPrefix(args, "c_"))
+		if err != nil {
+			log.Printf("Error getting campaign %q: %v", args, err)
+		}
+		if campaign != nil {
+			payload.Campaign = campaign
+			// Пришедшие по ссылке кампании засчитываются ее владельцу как рефералы
+			if campaign.OwnerID != nil && *campaign.OwnerID != userID {
+				payload.ReferrerID = campaign.OwnerID
+			}
+		}
+	default:
+		if referrerID, err := h.db.ResolveReferralCode(args); err != nil {
+			log.Printf("Error resolving referral code %q: %v", args, err)
+		} else if referrerID != nil {
+			if *referrerID != userID {
+				payload.ReferrerID = referrerID
+				payload.ReferralCode = args
+			}
+		} else if referrerID, err := strconv.ParseInt(args, 10, 64); err == nil {
+			if referrerID != userID {
+				// Проверяем, существует ли реферер
+				if referrer, _ := h.db.GetUser(referrerID); referrer != nil {
+					payload.ReferrerID = &referrerID
+				}
+			}
+		}
+	}
+
+	return payload
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// requireSubscription показывает список обязательных чатов и возвращает true,
+// если пользователь подписан не на все из них
+func (h *UserHandler) requireSubscription(user *models.User) bool {
+	textKey := "subscription_required"
+	missing, err := h.subs.Missing(user.UserID)
+	if err != nil {
+		// Бот не может проверить подписку (например, не добавлен в канал) - не пропускаем
+		// пользователя к наградам и выводу, пока проверка не заработает
+		log.Printf("Error checking subscription for user %d: %v", user.UserID, err)
+		textKey, missing = "subscription_check_failed", h.subs.Chats()
+	}
+	if len(missing) == 0 {
+		return false
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, chat := range missing {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(h.loc.Get(user.Language, "btn_subscription_join", localization.Args{"Chat": chat.String()}), chat.Link),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_subscription_check"), "sub_check"),
+	))
+
+	text := h.loc.Get(user.Language, textKey)
+	msg := tgbotapi.NewMessage(user.UserID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	h.bot.Send(msg)
+	return true
+}
+
+// handleSubscriptionCheck перепроверяет подписку без учета кэша после нажатия "Я подписался"
+func (h *UserHandler) handleSubscriptionCheck(query *tgbotapi.CallbackQuery, user *models.User) {
+	h.subs.Invalidate(user.UserID)
+
+	missing, err := h.subs.Missing(user.UserID)
+	if err != nil {
+		log.Printf("Error checking subscription for user %d: %v", user.UserID, err)
+		callback := tgbotapi.NewCallbackWithAlert(query.ID, h.loc.Get(user.Language, "subscription_check_failed"))
+		h.bot.Request(callback)
+		return
+	}
+	if len(missing) > 0 {
+		callback := tgbotapi.NewCallbackWithAlert(query.ID, h.loc.Get(user.Language, "subscription_still_missing"))
+		h.bot.Request(callback)
+		return
+	}
+
+	h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+	h.clearInlineKeyboard(query)
+
+	h.checkReferral(user.UserID)
+	unlockWelcomeBonus(h.bot, h.db, h.loc, h.config.WelcomeBonusUnlock, user.UserID)
+	h.sendUserMenu(user.UserID, user.Language)
+}
+
+// subscribed проверяет подписку молча, для условий подтверждения реферала.
+// Если проверить не удалось, условие считается невыполненным
+func (h *UserHandler) subscribed(userID int64) bool {
+	missing, err := h.subs.Missing(userID)
+	if err != nil {
+		log.Printf("Error checking subscription for user %d: %v", userID, err)
+		return false
+	}
+	return len(missing) == 0
+}
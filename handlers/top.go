@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const topSize = 10
+
+// contestTitle возвращает заголовок рейтинга конкурса: метрику, призовой фонд и число мест
+func contestTitle(loc *localization.Localization, lang string, contest *models.Contest) string {
+	return loc.Get(lang, "top_contest_title", localization.Args{
+		"Metric":    loc.Get(lang, "metric_"+contest.Metric),
+		"PrizePool": contest.PrizePool,
+		"Count":     contest.Winners,
+		"EndsAt":    contest.EndsAt.Format(cardDateLayout),
+	})
+}
+
+// formatRankValue выводит значение метрики: количество рефералов целым числом, сумму - в USDT
+func formatRankValue(loc *localization.Localization, lang, metric string, value float64) string {
+	if metric == models.MetricEarned {
+		return loc.Get(lang, "top_value_earned", localization.Args{"Value": value})
+	}
+	return loc.Get(lang, "top_value_referrals", localization.Args{"Value": int(value)})
+}
+
+// writeRanking дописывает строки рейтинга; label форматирует пользователя
+func writeRanking(b *strings.Builder, loc *localization.Localization, lang, metric string, entries []models.RankEntry, label func(int64) string) {
+	if len(entries) == 0 {
+		b.WriteString("\n")
+		b.WriteString(loc.Get(lang, "top_empty"))
+		return
+	}
+
+	for _, entry := range entries {
+		b.WriteString("\n")
+		b.WriteString(loc.Get(lang, "top_line", localization.Args{
+			"Rank":  entry.Rank,
+			"User":  label(entry.UserID),
+			"Value": formatRankValue(loc, lang, metric, entry.Value),
+		}))
+	}
+}
+
+// HandleTopCommand показывает /top: рейтинг текущего конкурса или общий рейтинг рефереров
+func (h *UserHandler) HandleTopCommand(update tgbotapi.Update) {
+	userID := update.Message.From.ID
+
+	user, err := h.db.GetUser(userID)
+	if err != nil || user == nil || user.Banned {
+		return
+	}
+	lang := user.Language
+
+	contest, err := h.db.GetActiveContest()
+	if err != nil {
+		log.Printf("Error getting active contest: %v", err)
+		return
+	}
+
+	var b strings.Builder
+	metric := models.MetricReferrals
+	since, until := time.Time{}, time.Now()
+
+	if contest != nil {
+		metric = contest.Metric
+		since, until = contest.StartsAt, contest.EndsAt
+		b.WriteString(contestTitle(h.loc, lang, contest))
+	} else {
+		b.WriteString(h.loc.Get(lang, "top_title"))
+	}
+	b.WriteString("\n")
+
+	entries, err := h.db.Ranking(metric, since, until, topSize)
+	if err != nil {
+		log.Printf("Error getting ranking: %v", err)
+		return
+	}
+	writeRanking(&b, h.loc, lang, metric, entries, h.userLabel)
+
+	b.WriteString("\n\n")
+	rank, err := h.db.UserRank(metric, since, until, userID)
+	if err != nil {
+		log.Printf("Error getting rank for user %d: %v", userID, err)
+	}
+	if rank != nil {
+		b.WriteString(h.loc.Get(lang, "top_your_rank",
+			localization.Args{"Rank": rank.Rank, "Value": formatRankValue(h.loc, lang, metric, rank.Value)}))
+	} else {
+		b.WriteString(h.loc.Get(lang, "top_not_ranked"))
+	}
+
+	msg := tgbotapi.NewMessage(userID, b.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
+
+// contestRankingText - текущая таблица конкурса для администратора
+func contestRankingText(db *database.Database, loc *localization.Localization, lang string, contest *models.Contest) (string, error) {
+	entries, err := db.Ranking(contest.Metric, contest.StartsAt, contest.EndsAt, topSize)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString(contestTitle(loc, lang, contest))
+	b.WriteString("\n")
+	writeRanking(&b, loc, lang, contest.Metric, entries, codeLabel)
+	return b.String(), nil
+}
@@ -1,15 +1,16 @@
 package handlers
 
 import (
-	"fmt"
 	"log"
 	"regexp"
-	"strconv"
 	"strings"
+	"telegram-bot/captcha"
 	"telegram-bot/config"
 	"telegram-bot/database"
+	"telegram-bot/fraud"
 	"telegram-bot/localization"
 	"telegram-bot/models"
+	"telegram-bot/subscription"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -20,6 +21,8 @@ type UserHandler struct {
 	config   *config.Config
 	loc      *localization.Localization
 	sessions map[int64]*models.UserSession
+	subs     *subscription.Checker
+	fraud    *fraud.Engine
 }
 
 func NewUserHandler(bot *tgbotapi.BotAPI, db *database.Database, cfg *config.Config, loc *localization.Localization) *UserHandler {
@@ -29,6 +32,8 @@ func NewUserHandler(bot *tgbotapi.BotAPI, db *database.Database, cfg *config.Con
 		config:   cfg,
 		loc:      loc,
 		sessions: make(map[int64]*models.UserSession),
+		subs:     subscription.NewChecker(bot, cfg.RequiredChats, cfg.SubscriptionCache),
+		fraud:    fraud.NewEngine(fraud.DefaultRules(cfg.FraudThresholds), cfg.FraudThreshold),
 	}
 }
 
@@ -42,62 +47,127 @@ func (h *UserHandler) HandleStart(update tgbotapi.Update) {
 		return
 	}
 
+	payload := h.parseStartPayload(userID, update.Message.CommandArguments())
+
 	if user == nil {
-		// Новый пользователь - создаем и показываем выбор языка
-		var referredBy *int64
-		if update.Message.CommandArguments() != "" {
-			if referrerID, err := strconv.ParseInt(update.Message.CommandArguments(), 10, 64); err == nil {
-				if referrerID != userID {
-					// Проверяем, существует ли реферер
-					if referrer, _ := h.db.GetUser(referrerID); referrer != nil {
-						referredBy = &referrerID
-					}
-				}
-			}
+		// Новый пользователь - язык берем из клиента Telegram, если он переведен
+		referredBy := payload.ReferrerID
+		bonus, bonusSource := h.welcomeBonus(payload)
+		lang, detected := h.loc.Match(update.Message.From.LanguageCode)
+		if !detected {
+			lang = localization.DefaultLanguage
 		}
 
-		if err := h.db.CreateUser(userID, referredBy, h.config.RewardAmount); err != nil {
+		err := h.db.CreateUser(database.NewUser{
+			UserID:             userID,
+			ReferredBy:         referredBy,
+			Language:           lang,
+			ReferralCode:       payload.ReferralCode,
+			Campaign:           payload.Campaign,
+			CaptchaRequired:    h.config.CaptchaMode != captcha.ModeOff,
+			WelcomeBonus:       bonus,
+			WelcomeBonusSource: bonusSource,
+		})
+		if err != nil {
 			log.Printf("Error creating user %d: %v", userID, err)
 			return
 		}
 
-		// Уведомляем реферера
+		// Награда будет начислена после подтверждения реферала
 		if referredBy != nil {
-			referrerUser, _ := h.db.GetUser(*referredBy)
-			if referrerUser != nil {
-				text := h.loc.Get(referrerUser.Language, "new_referral_notification", h.config.RewardAmount)
-				msg := tgbotapi.NewMessage(*referredBy, text)
+			if referrer, _ := h.db.GetUser(*referredBy); referrer != nil {
+				text := h.loc.Get(referrer.Language, "new_referral_pending")
+				msg := tgbotapi.NewMessage(referrer.UserID, text)
 				h.bot.Send(msg)
 			}
 		}
 
-		// Показываем выбор языка только для новых пользователей
-		h.sendLanguageSelection(userID)
-	} else {
+		if !detected {
+			// Язык не распознан - показываем выбор языка
+			h.sendLanguageSelection(userID)
+		} else {
+			// Переведенный язык клиента засчитывается как выбор языка для подтверждения реферала
+			if err := h.db.MarkReferralLanguageChosen(userID, models.LanguageDetected); err != nil {
+				log.Printf("Error marking referral language for user %d: %v", userID, err)
+			}
+			h.checkReferral(userID)
+
+			if h.continueRegistration(userID) {
+				h.sendUserMenu(userID, lang)
+			}
+		}
+	} else if user.Banned {
+		h.sendBanned(userID, user.Language)
+		return
+	} else if !h.requireCaptcha(user) && !h.requireSubscription(user) {
 		// Существующий пользователь - показываем профиль на его языке
 		h.sendUserMenu(userID, user.Language)
 	}
+
+	// Пользователь открыл ссылку на подарок
+	if payload.VoucherCode != "" {
+		h.claimVoucher(userID, payload.VoucherCode)
+	}
 }
 
 func (h *UserHandler) sendLanguageSelection(userID int64) {
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("English 🇬🇧", "lang_en"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("Русский 🇷🇺", "lang_ru"),
-		),
-	)
+	// Приветствие собирается из всех загруженных переводов
+	var welcome []string
+	for _, lang := range h.loc.GetSupportedLanguages() {
+		welcome = append(welcome, h.loc.Get(lang, "welcome"))
+	}
 
-	text := h.loc.Get("ru", "welcome") + "\n\n" + h.loc.Get("en", "welcome")
+	text := strings.Join(welcome, "\n\n")
 	msg := tgbotapi.NewMessage(userID, text)
-	msg.ReplyMarkup = keyboard
+	msg.ReplyMarkup = h.languageKeyboard("")
+	h.bot.Send(msg)
+}
+
+// languageKeyboard - кнопки всех загруженных языков, текущий отмечен галочкой
+func (h *UserHandler) languageKeyboard(current string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, lang := range h.loc.GetSupportedLanguages() {
+		name := h.loc.Name(lang)
+		if lang == current {
+			name = "✅ " + name
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(name, "lang_"+lang),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// HandleLanguageCommand обрабатывает /language - смену языка после регистрации
+func (h *UserHandler) HandleLanguageCommand(update tgbotapi.Update) {
+	userID := update.Message.From.ID
+
+	user, err := h.db.GetUser(userID)
+	if err != nil || user == nil {
+		return
+	}
+	if user.Banned {
+		h.sendBanned(userID, user.Language)
+		return
+	}
+
+	h.sendLanguagePicker(userID, user.Language)
+}
+
+func (h *UserHandler) sendLanguagePicker(userID int64, lang string) {
+	text := h.loc.Get(lang, "language_choose")
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ReplyMarkup = h.languageKeyboard(lang)
 	h.bot.Send(msg)
 }
 
 func (h *UserHandler) HandleLanguageSelection(query *tgbotapi.CallbackQuery) {
 	userID := query.From.ID
 	langCode := strings.TrimPrefix(query.Data, "lang_")
+	if !h.loc.Has(langCode) {
+		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
 
 	// Сохраняем язык в базу
 	if err := h.db.UpdateUserLanguage(userID, langCode); err != nil {
@@ -105,14 +175,41 @@ func (h *UserHandler) HandleLanguageSelection(query *tgbotapi.CallbackQuery) {
 		return
 	}
 
+	// Выбор языка - одно из условий подтверждения реферала
+	if err := h.db.MarkReferralLanguageChosen(userID, models.LanguagePicked); err != nil {
+		log.Printf("Error marking referral language for user %d: %v", userID, err)
+	}
+	h.checkReferral(userID)
+
 	// Показываем полный профиль пользователя
-	h.showUserProfile(query, langCode)
+	if h.continueRegistration(userID) {
+		h.showUserProfile(query, langCode)
+	}
 
 	// Подтверждаем обработку callback
 	callback := tgbotapi.NewCallback(query.ID, "")
 	h.bot.Request(callback)
 }
 
+// continueRegistration продолжает регистрацию после того, как язык известен (выбран
+// вручную или определен по клиенту Telegram). Возвращает true, если можно показать
+// профиль: капча пройдена и подписки оформлены, иначе пользователю уже отправлено задание
+func (h *UserHandler) continueRegistration(userID int64) bool {
+	// До прохождения капчи вместо профиля показываем задание
+	user, err := h.db.GetUser(userID)
+	if err != nil || user == nil {
+		log.Printf("Error getting user %d after language selection: %v", userID, err)
+		return false
+	}
+	if h.requireCaptcha(user) {
+		return false
+	}
+
+	h.onboard(userID)
+
+	return !h.requireSubscription(user)
+}
+
 func (h *UserHandler) showUserProfile(query *tgbotapi.CallbackQuery, lang string) {
 	userID := query.From.ID
 	
@@ -123,60 +220,8 @@ func (h *UserHandler) showUserProfile(query *tgbotapi.CallbackQuery, lang string
 		return
 	}
 
-	// Формируем реферальную ссылку
-	referralLink := fmt.Sprintf("https://t.me/%s?start=%d", h.bot.Self.UserName, user.UserID)
-	
-	// Количество рефералов
-	referralCount := len(user.Referrals)
-	
-	// Формируем текст профиля (убрали дату регистрации)
-	var profileText string
-	if lang == "ru" {
-		profileText = fmt.Sprintf(
-			"🤑 <b>Дарите подарки и зарабатывайте</b>\n\n"+
-			"Отправляйте друзьям подарки и получайте криптовалюту.\n"+
-			"Вывод станет доступен при накоплении %.2f USDT на балансе.\n\n"+
-			"<b>Ваша реферальная ссылка:</b>\n"+
-			"<code>%s</code>\n\n"+
-			"У вас <b>%d подтвержденных рефералов</b>\n\n"+
-			"<b>Ваш баланс:</b> %.2f USDT (%.2f$)\n\n"+
-			"<b>Ваш ID:</b> <code>%d</code>",
-			h.config.MinWithdrawalAmount,
-			referralLink,
-			referralCount,
-			user.Balance,
-			user.Balance,
-			user.UserID,
-		)
-	} else {
-		profileText = fmt.Sprintf(
-			"🤑 <b>Give gifts and earn</b>\n\n"+
-			"Send gifts to friends and get cryptocurrency.\n"+
-			"Withdrawal will be available when accumulating %.2f USDT on balance.\n\n"+
-			"<b>Your referral link:</b>\n"+
-			"<code>%s</code>\n\n"+
-			"You have <b>%d confirmed referrals</b>\n\n"+
-			"<b>Your balance:</b> %.2f USDT (%.2f$)\n\n"+
-			"<b>Your ID:</b> <code>%d</code>",
-			h.config.MinWithdrawalAmount,
-			referralLink,
-			referralCount,
-			user.Balance,
-			user.Balance,
-			user.UserID,
-		)
-	}
-
-	// Создаем клавиатуру
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_balance"), "user_balance"),
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_withdraw"), "user_withdraw"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_gift"), "user_gift"),
-		),
-	)
+	profileText := h.profileText(user, lang)
+	keyboard := h.profileKeyboard(lang)
 
 	// Редактируем сообщение
 	edit := tgbotapi.NewEditMessageText(query.From.ID, query.Message.MessageID, profileText)
@@ -192,64 +237,44 @@ func (h *UserHandler) sendUserMenu(userID int64, lang string) {
 		return
 	}
 
-	// Формируем реферальную ссылку
-	referralLink := fmt.Sprintf("https://t.me/%s?start=%d", h.bot.Self.UserName, user.UserID)
-	
-	// Количество рефералов
-	referralCount := len(user.Referrals)
-	
-	// Формируем текст профиля (убрали дату регистрации)
-	var profileText string
-	if lang == "ru" {
-		profileText = fmt.Sprintf(
-			"🤑 <b>Дарите подарки и зарабатывайте</b>\n\n"+
-			"Отправляйте друзьям подарки и получайте криптовалюту.\n"+
-			"Вывод станет доступен при накоплении %.2f USDT на балансе.\n\n"+
-			"<b>Ваша реферальная ссылка:</b>\n"+
-			"<code>%s</code>\n\n"+
-			"У вас <b>%d подтвержденных рефералов</b>\n\n"+
-			"<b>Ваш баланс:</b> %.2f USDT (%.2f$)\n\n"+
-			"<b>Ваш ID:</b> <code>%d</code>",
-			h.config.MinWithdrawalAmount,
-			referralLink,
-			referralCount,
-			user.Balance,
-			user.Balance,
-			user.UserID,
-		)
-	} else {
-		profileText = fmt.Sprintf(
-			"🤑 <b>Give gifts and earn</b>\n\n"+
-			"Send gifts to friends and get cryptocurrency.\n"+
-			"Withdrawal will be available when accumulating %.2f USDT on balance.\n\n"+
-			"<b>Your referral link:</b>\n"+
-			"<code>%s</code>\n\n"+
-			"You have <b>%d confirmed referrals</b>\n\n"+
-			"<b>Your balance:</b> %.2f USDT (%.2f$)\n\n"+
-			"<b>Your ID:</b> <code>%d</code>",
-			h.config.MinWithdrawalAmount,
-			referralLink,
-			referralCount,
-			user.Balance,
-			user.Balance,
-			user.UserID,
-		)
-	}
-
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+	profileText := h.profileText(user, lang)
+	keyboard := h.profileKeyboard(lang)
+
+	msg := tgbotapi.NewMessage(userID, profileText)
+	msg.ReplyMarkup = keyboard
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+}
+
+// profileText собирает текст профиля из шаблона profile
+func (h *UserHandler) profileText(user *models.User, lang string) string {
+	return h.loc.Get(lang, "profile", localization.Args{
+		"MinWithdrawal": h.config.MinWithdrawalAmount,
+		"ReferralLink":  h.referralLink(user.UserID),
+		"Confirmed":     user.ConfirmedReferrals(),
+		"Pending":       user.PendingReferrals,
+		"Balance":       user.Balance,
+		// Заблокированный или уже зачисленный приветственный бонус
+		"WelcomeBonus": h.welcomeBonusLine(user.UserID, lang),
+		"UserID":       user.UserID,
+	})
+}
+
+func (h *UserHandler) profileKeyboard(lang string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_balance"), "user_balance"),
 			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_withdraw"), "user_withdraw"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_gift"), "user_gift"),
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_ref_codes"), "user_ref_codes"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_my_referrals"), referralsPageCallback(0)),
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(lang, "btn_language"), "user_language"),
 		),
 	)
-
-	msg := tgbotapi.NewMessage(userID, profileText)
-	msg.ReplyMarkup = keyboard
-	msg.ParseMode = tgbotapi.ModeHTML
-	h.bot.Send(msg)
 }
 
 func (h *UserHandler) HandleUserCallback(query *tgbotapi.CallbackQuery) {
@@ -259,6 +284,27 @@ func (h *UserHandler) HandleUserCallback(query *tgbotapi.CallbackQuery) {
 		return
 	}
 
+	if user.Banned {
+		callback := tgbotapi.NewCallback(query.ID, h.loc.Get(user.Language, "user_banned"))
+		h.bot.Request(callback)
+		return
+	}
+
+	// Пока капча не пройдена, остальные действия недоступны
+	if strings.HasPrefix(query.Data, "captcha_") {
+		h.handleCaptchaCallback(query, user)
+		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	if h.requireCaptcha(user) {
+		h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return
+	}
+	if query.Data == "sub_check" {
+		h.handleSubscriptionCheck(query, user)
+		return
+	}
+
 	switch query.Data {
 	case "user_balance":
 		h.handleBalance(query, user)
@@ -266,8 +312,22 @@ func (h *UserHandler) HandleUserCallback(query *tgbotapi.CallbackQuery) {
 		h.handleWithdrawStart(query, user)
 	case "user_gift":
 		h.handleGift(query, user)
+	case "user_ref_codes":
+		h.sendReferralCodes(user)
+	case "user_ref_code_new":
+		h.handleNewReferralCode(user)
+	case "user_language":
+		h.sendLanguagePicker(user.UserID, user.Language)
 	case "main_menu":
-		h.showUserProfile(query, user.Language)
+		if !h.requireSubscription(user) {
+			h.showUserProfile(query, user.Language)
+		}
+	default:
+		if strings.HasPrefix(query.Data, "gift_") {
+			h.handleGiftCallback(query, user)
+		} else if strings.HasPrefix(query.Data, "user_refs_") {
+			h.handleReferralsPage(query, user)
+		}
 	}
 
 	callback := tgbotapi.NewCallback(query.ID, "")
@@ -275,6 +335,11 @@ func (h *UserHandler) HandleUserCallback(query *tgbotapi.CallbackQuery) {
 }
 
 func (h *UserHandler) handleBalance(query *tgbotapi.CallbackQuery, user *models.User) {
+	// Баланс показывается вместе с профилем, поэтому проверяется подписка, как в main_menu
+	if h.requireSubscription(user) {
+		return
+	}
+
 	// Получаем обновленные данные пользователя
 	freshUser, err := h.db.GetUser(user.UserID)
 	if err != nil {
@@ -282,7 +347,7 @@ func (h *UserHandler) handleBalance(query *tgbotapi.CallbackQuery, user *models.
 		return
 	}
 
-	text := h.loc.Get(user.Language, "balance_display", freshUser.Balance)
+	text := h.loc.Get(user.Language, "balance_display", localization.Args{"Balance": freshUser.Balance})
 	msg := tgbotapi.NewMessage(query.From.ID, text)
 	h.bot.Send(msg)
 
@@ -291,9 +356,20 @@ func (h *UserHandler) handleBalance(query *tgbotapi.CallbackQuery, user *models.
 }
 
 func (h *UserHandler) handleWithdrawStart(query *tgbotapi.CallbackQuery, user *models.User) {
+	if user.Frozen {
+		text := h.loc.Get(user.Language, "withdraw_frozen")
+		msg := tgbotapi.NewMessage(query.From.ID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	if h.requireSubscription(user) {
+		return
+	}
+
 	if user.Balance < h.config.MinWithdrawalAmount {
 		text := h.loc.Get(user.Language, "withdraw_insufficient_funds",
-			h.config.MinWithdrawalAmount, user.Balance)
+			localization.Args{"MinAmount": h.config.MinWithdrawalAmount, "Balance": user.Balance})
 		msg := tgbotapi.NewMessage(query.From.ID, text)
 		h.bot.Send(msg)
 		return
@@ -305,13 +381,7 @@ func (h *UserHandler) handleWithdrawStart(query *tgbotapi.CallbackQuery, user *m
 		AwaitingWalletAmount: user.Balance,
 	}
 
-	text := h.loc.Get(user.Language, "withdraw_prompt", user.Balance)
-	msg := tgbotapi.NewMessage(query.From.ID, text)
-	h.bot.Send(msg)
-}
-
-func (h *UserHandler) handleGift(query *tgbotapi.CallbackQuery, user *models.User) {
-	text := h.loc.Get(user.Language, "gift_not_implemented")
+	text := h.loc.Get(user.Language, "withdraw_prompt", localization.Args{"Balance": user.Balance})
 	msg := tgbotapi.NewMessage(query.From.ID, text)
 	h.bot.Send(msg)
 }
@@ -331,13 +401,25 @@ func (h *UserHandler) HandleMessage(update tgbotapi.Update) {
 	}
 
 	user, err := h.db.GetUser(userID)
-	if err != nil || user == nil {
+	if err != nil || user == nil || user.Banned {
 		return
 	}
 
 	switch session.State {
 	case "awaiting_wallet":
 		h.handleWalletAddress(update.Message, user, session)
+	case "awaiting_gift_recipient":
+		h.handleGiftRecipient(update.Message, user, session)
+	case "awaiting_gift_amount":
+		h.handleGiftAmount(update.Message, user, session)
+	case "awaiting_gift_note":
+		h.handleGiftNote(update.Message, user, session)
+	case "awaiting_voucher_amount":
+		h.handleVoucherAmount(update.Message, user, session)
+	case "awaiting_voucher_recipient":
+		h.handleVoucherRecipient(update.Message, user, session)
+	case "awaiting_ref_code_name":
+		h.handleReferralCodeName(update.Message, user)
 	}
 }
 
@@ -355,28 +437,111 @@ func (h *UserHandler) handleWalletAddress(message *tgbotapi.Message, user *model
 
 	amount := session.AwaitingWalletAmount
 
+	// Администратор мог заморозить вывод, пока пользователь вводил кошелек
+	if user.Frozen {
+		text := h.loc.Get(user.Language, "withdraw_frozen")
+		msg := tgbotapi.NewMessage(message.From.ID, text)
+		h.bot.Send(msg)
+		delete(h.sessions, user.UserID)
+		return
+	}
+
+	// Списываем средства и создаем заявку
+	withdrawal, err := h.db.CreateWithdrawal(user.UserID, amount, walletAddress)
+	if err != nil {
+		switch err {
+		case database.ErrInsufficientFunds:
+			freshUser, _ := h.db.GetUser(user.UserID)
+			if freshUser != nil {
+				text := h.loc.Get(user.Language, "withdraw_insufficient_funds",
+					localization.Args{"MinAmount": h.config.MinWithdrawalAmount, "Balance": freshUser.Balance})
+				msg := tgbotapi.NewMessage(message.From.ID, text)
+				h.bot.Send(msg)
+			}
+		case database.ErrWithdrawalRestricted:
+			text := h.loc.Get(user.Language, "withdraw_frozen")
+			msg := tgbotapi.NewMessage(message.From.ID, text)
+			h.bot.Send(msg)
+		default:
+			log.Printf("Error creating withdrawal for user %d: %v", user.UserID, err)
+		}
+		delete(h.sessions, user.UserID)
+		return
+	}
+
 	// Уведомляем пользователя об успехе
-	text := h.loc.Get(user.Language, "withdraw_success_user", amount, walletAddress)
+	text := h.loc.Get(user.Language, "withdraw_success_user", localization.Args{"Amount": amount, "Wallet": walletAddress})
 	msg := tgbotapi.NewMessage(message.From.ID, text)
 	msg.ParseMode = tgbotapi.ModeHTML
 	h.bot.Send(msg)
 
-	// Уведомляем админов
-	adminText := h.loc.Get("ru", "admin_withdrawal_notification",
-		user.UserID, amount, walletAddress)
-	for _, adminID := range h.config.AdminUserIDs {
+	// Уведомляем админов, которые могут подтверждать выводы
+	adminIDs, err := h.db.GetAdminIDsWithPermission(models.PermApproveWithdrawals)
+	if err != nil {
+		log.Printf("Error getting admins for withdrawal %d: %v", withdrawal.ID, err)
+	}
+	for _, adminID := range adminIDs {
+		adminLang := "ru"
+		if admin, _ := h.db.GetUser(adminID); admin != nil {
+			adminLang = admin.Language
+		}
+
+		adminText := h.loc.Get(adminLang, "admin_withdrawal_notification",
+			localization.Args{"UserID": user.UserID, "Amount": amount, "Wallet": walletAddress})
 		adminMsg := tgbotapi.NewMessage(adminID, adminText)
 		adminMsg.ParseMode = tgbotapi.ModeHTML
+		adminMsg.ReplyMarkup = withdrawalKeyboard(h.loc, adminLang, withdrawal.ID)
 		h.bot.Send(adminMsg)
 	}
 
-	// Обнуляем баланс
-	h.db.UpdateUserBalance(user.UserID, 0.0)
-
 	// Очищаем сессию
 	delete(h.sessions, user.UserID)
 }
 
+// TrackActivity обновляет username и время последней активности пользователя
+func (h *UserHandler) TrackActivity(from *tgbotapi.User) {
+	if from == nil {
+		return
+	}
+
+	newDay, err := h.db.TouchUser(from.ID, from.UserName)
+	if err != nil {
+		log.Printf("Error tracking activity for user %d: %v", from.ID, err)
+		return
+	}
+
+	// Условие active_days может выполниться только с новым днем активности,
+	// остальные условия проверяются там, где они выполняются
+	if newDay {
+		h.checkReferral(from.ID)
+	}
+}
+
+func (h *UserHandler) sendBanned(userID int64, lang string) {
+	text := h.loc.Get(lang, "user_banned")
+	msg := tgbotapi.NewMessage(userID, text)
+	h.bot.Send(msg)
+}
+
+// HandleMyChatMember отслеживает блокировку и разблокировку бота в личном чате
+func (h *UserHandler) HandleMyChatMember(update *tgbotapi.ChatMemberUpdated) {
+	if !update.Chat.IsPrivate() {
+		return
+	}
+
+	userID := update.From.ID
+	switch update.NewChatMember.Status {
+	case "kicked":
+		if err := h.db.SetUserActive(userID, false); err != nil {
+			log.Printf("Error marking user %d inactive: %v", userID, err)
+		}
+	case "member":
+		if err := h.db.SetUserActive(userID, true); err != nil {
+			log.Printf("Error marking user %d active: %v", userID, err)
+		}
+	}
+}
+
 func (h *UserHandler) handleCancel(userID int64) {
 	user, err := h.db.GetUser(userID)
 	if err != nil || user == nil {
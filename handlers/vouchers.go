@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *UserHandler) startVoucher(user *models.User) {
+	if user.Frozen {
+		text := h.loc.Get(user.Language, "withdraw_frozen")
+		msg := tgbotapi.NewMessage(user.UserID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	h.sessions[user.UserID] = &models.UserSession{
+		State: "awaiting_voucher_amount",
+	}
+
+	text := h.loc.Get(user.Language, "gift_prompt_amount",
+		localization.Args{"Available": h.giftAvailable(user), "Min": h.config.GiftMinAmount})
+	msg := tgbotapi.NewMessage(user.UserID, text)
+	h.bot.Send(msg)
+}
+
+func (h *UserHandler) handleVoucherAmount(message *tgbotapi.Message, user *models.User, session *models.UserSession) {
+	amount, ok := h.readGiftAmount(message, user)
+	if !ok {
+		return
+	}
+
+	session.GiftAmount = amount
+	session.State = "awaiting_voucher_recipient"
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_voucher_anyone"), "gift_voucher_anyone"),
+		),
+	)
+
+	text := h.loc.Get(user.Language, "voucher_prompt_recipient")
+	msg := tgbotapi.NewMessage(message.From.ID, text)
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+func (h *UserHandler) handleVoucherRecipient(message *tgbotapi.Message, user *models.User, session *models.UserSession) {
+	recipient, ok := h.readGiftRecipient(message, user)
+	if !ok {
+		return
+	}
+
+	session.GiftRecipientID = recipient.UserID
+	h.sendVoucherConfirmation(user, session)
+}
+
+func (h *UserHandler) sendVoucherConfirmation(user *models.User, session *models.UserSession) {
+	session.State = "awaiting_voucher_confirm"
+
+	recipient := h.loc.Get(user.Language, "voucher_anyone")
+	if session.GiftRecipientID != 0 {
+		recipient = h.userLabel(session.GiftRecipientID)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_confirm"), "gift_voucher_confirm"),
+			tgbotapi.NewInlineKeyboardButtonData(h.loc.Get(user.Language, "btn_cancel"), "gift_cancel"),
+		),
+	)
+
+	text := h.loc.Get(user.Language, "voucher_confirm", localization.Args{
+		"Amount":    session.GiftAmount,
+		"Recipient": recipient,
+		"Hours":     int(h.config.VoucherTTL.Hours()),
+	})
+	msg := tgbotapi.NewMessage(user.UserID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard
+	h.bot.Send(msg)
+}
+
+func voucherLink(botName, code string) string {
+	return fmt.Sprintf("https://t.me/%s?start=gift_%s", botName, code)
+}
+
+func (h *UserHandler) completeVoucher(sender *models.User, session *models.UserSession) {
+	var recipientID *int64
+	if session.GiftRecipientID != 0 {
+		recipientID = &session.GiftRecipientID
+	}
+
+	limits := database.GiftLimits{
+		DailyLimit: h.config.GiftDailyLimit,
+		MinBalance: h.config.GiftMinBalance,
+	}
+
+	voucher, err := h.db.CreateVoucher(sender.UserID, session.GiftAmount, recipientID, h.config.VoucherTTL, limits)
+	if err != nil {
+		var key string
+		switch err {
+		case database.ErrInsufficientFunds, database.ErrGiftMinBalance, database.ErrGiftDailyLimit:
+			key = "gift_limit_reached"
+		case database.ErrGiftSenderRestricted:
+			key = "withdraw_frozen"
+		default:
+			log.Printf("Error creating voucher for user %d: %v", sender.UserID, err)
+			key = "gift_failed"
+		}
+
+		text := h.loc.Get(sender.Language, key)
+		msg := tgbotapi.NewMessage(sender.UserID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	link := voucherLink(h.bot.Self.UserName, voucher.Code)
+
+	text := h.loc.Get(sender.Language, "voucher_created", localization.Args{
+		"Amount":    voucher.Amount,
+		"Link":      link,
+		"ExpiresAt": voucher.ExpiresAt.Format(cardDateLayout),
+	})
+	msg := tgbotapi.NewMessage(sender.UserID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+
+	// Адресный ваучер сразу отправляем получателю
+	if recipientID != nil {
+		if recipient, _ := h.db.GetUser(*recipientID); recipient != nil {
+			text := h.loc.Get(recipient.Language, "voucher_for_you",
+				localization.Args{"Amount": voucher.Amount, "Sender": h.userLabel(sender.UserID), "Link": link})
+			msg := tgbotapi.NewMessage(recipient.UserID, text)
+			msg.ParseMode = tgbotapi.ModeHTML
+			h.bot.Send(msg)
+		}
+	}
+}
+
+func (h *UserHandler) claimVoucher(userID int64, code string) {
+	user, err := h.db.GetUser(userID)
+	if err != nil || user == nil {
+		return
+	}
+
+	voucher, err := h.db.ClaimVoucher(code, userID)
+	if err != nil {
+		var key string
+		switch err {
+		case database.ErrVoucherNotFound:
+			key = "voucher_not_found"
+		case database.ErrVoucherNotActive:
+			key = "voucher_not_active"
+		case database.ErrVoucherNotForUser:
+			key = "voucher_not_for_you"
+		case database.ErrVoucherOwn:
+			key = "voucher_own"
+		case database.ErrVoucherBanned:
+			h.sendBanned(userID, user.Language)
+			return
+		case database.ErrVoucherCaptchaRequired:
+			if err := h.db.SetPendingVoucher(userID, code); err != nil {
+				log.Printf("Error saving pending voucher %s for %d: %v", code, userID, err)
+			}
+			key = "voucher_after_captcha"
+		default:
+			log.Printf("Error claiming voucher %s by %d: %v", code, userID, err)
+			key = "gift_failed"
+		}
+
+		text := h.loc.Get(user.Language, key)
+		msg := tgbotapi.NewMessage(userID, text)
+		h.bot.Send(msg)
+		return
+	}
+
+	text := h.loc.Get(user.Language, "gift_received", localization.Args{"Amount": voucher.Amount, "Sender": h.userLabel(voucher.SenderID)})
+	msg := tgbotapi.NewMessage(userID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	h.bot.Send(msg)
+
+	if sender, _ := h.db.GetUser(voucher.SenderID); sender != nil {
+		text := h.loc.Get(sender.Language, "voucher_claimed_sender", localization.Args{"Amount": voucher.Amount, "Recipient": h.userLabel(userID)})
+		msg := tgbotapi.NewMessage(sender.UserID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		h.bot.Send(msg)
+	}
+}
+
+// claimPendingVoucher выдает ваучер, ссылку на который пользователь открыл до прохождения капчи
+func (h *UserHandler) claimPendingVoucher(userID int64) {
+	code, err := h.db.TakePendingVoucher(userID)
+	if err != nil {
+		log.Printf("Error getting pending voucher for %d: %v", userID, err)
+		return
+	}
+	if code != "" {
+		h.claimVoucher(userID, code)
+	}
+}
+
+// RunVoucherExpiry периодически возвращает отправителям суммы непринятых ваучеров
+func (h *UserHandler) RunVoucherExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := h.db.ExpireVouchers()
+		if err != nil {
+			log.Printf("Error expiring vouchers: %v", err)
+		}
+
+		for _, voucher := range expired {
+			sender, _ := h.db.GetUser(voucher.SenderID)
+			if sender == nil {
+				continue
+			}
+
+			text := h.loc.Get(sender.Language, "voucher_expired", localization.Args{"Amount": voucher.Amount})
+			msg := tgbotapi.NewMessage(sender.UserID, text)
+			h.bot.Send(msg)
+		}
+	}
+}
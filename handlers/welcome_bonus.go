@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"telegram-bot/database"
+	"telegram-bot/localization"
+	"telegram-bot/models"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// welcomeBonus возвращает приветственный бонус нового пользователя и его источник.
+// Бонус кампании заменяет стандартный бонус за приглашение
+func (h *UserHandler) welcomeBonus(payload startPayload) (float64, string) {
+	if payload.Campaign != nil && payload.Campaign.WelcomeBonus > 0 {
+		return payload.Campaign.WelcomeBonus, "campaign " + payload.Campaign.Slug
+	}
+	if payload.ReferrerID != nil && h.config.WelcomeBonus > 0 {
+		return h.config.WelcomeBonus, models.BonusSourceReferral
+	}
+	return 0, ""
+}
+
+// onboard отмечает, что пользователь выбрал язык и прошел капчу, и пробует разблокировать бонус
+func (h *UserHandler) onboard(userID int64) {
+	if err := h.db.MarkOnboarded(userID); err != nil {
+		log.Printf("Error marking user %d onboarded: %v", userID, err)
+		return
+	}
+	unlockWelcomeBonus(h.bot, h.db, h.loc, h.config.WelcomeBonusUnlock, userID)
+}
+
+// unlockWelcomeBonus зачисляет бонус, если пользователь выполнил все условия, и уведомляет его
+func unlockWelcomeBonus(bot *tgbotapi.BotAPI, db *database.Database, loc *localization.Localization, conditions []string, userID int64) {
+	bonus, err := db.UnlockWelcomeBonus(userID, conditions)
+	if err != nil {
+		log.Printf("Error unlocking welcome bonus for %d: %v", userID, err)
+		return
+	}
+	if bonus == nil {
+		return
+	}
+
+	user, _ := db.GetUser(userID)
+	if user == nil {
+		return
+	}
+
+	text := loc.Get(user.Language, "welcome_bonus_unlocked_notification", localization.Args{"Amount": bonus.Amount})
+	msg := tgbotapi.NewMessage(userID, text)
+	bot.Send(msg)
+}
+
+// welcomeBonusLine возвращает строку профиля о приветственном бонусе или пустую строку.
+// Только читает состояние: бонус разблокируется при выполнении условий, а не при показе профиля
+func (h *UserHandler) welcomeBonusLine(userID int64, lang string) string {
+	bonus, err := h.db.GetWelcomeBonus(userID)
+	if err != nil {
+		log.Printf("Error getting welcome bonus for %d: %v", userID, err)
+		return ""
+	}
+	if bonus == nil {
+		return ""
+	}
+	if !bonus.Locked() {
+		return h.loc.Get(lang, "welcome_bonus_unlocked", localization.Args{"Amount": bonus.Amount})
+	}
+
+	unmet, err := h.db.UnmetBonusConditions(userID, h.config.WelcomeBonusUnlock)
+	if err != nil {
+		log.Printf("Error checking welcome bonus conditions for %d: %v", userID, err)
+		return ""
+	}
+	// Условия выполнены, но бонус еще не зачислен, например реферал на проверке
+	if len(unmet) == 0 {
+		return h.loc.Get(lang, "welcome_bonus_pending", localization.Args{"Amount": bonus.Amount})
+	}
+
+	conditions := make([]string, len(unmet))
+	for i, condition := range unmet {
+		conditions[i] = h.loc.Get(lang, "bonus_cond_"+condition)
+	}
+	return h.loc.Get(lang, "welcome_bonus_locked", localization.Args{
+		"Amount":     bonus.Amount,
+		"Conditions": strings.Join(conditions, ", "),
+	})
+}
@@ -0,0 +1,182 @@
+package localization
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	ErrUnknownLanguage = errors.New("unknown language")
+	ErrUnknownKey      = errors.New("unknown translation key")
+)
+
+// parseOverride разбирает текст администратора: JSON-объект с формами множественного
+// числа или обычная строка (в том числе шаблон, начинающийся с "{{")
+func parseOverride(text string) message {
+	if strings.HasPrefix(text, "{") && !strings.HasPrefix(text, "{{") {
+		var msg message
+		if err := json.Unmarshal([]byte(text), &msg); err == nil && msg.Forms != nil {
+			return msg
+		}
+	}
+	return message{Text: text}
+}
+
+// raw возвращает перевод в виде, в котором его можно отправить обратно в parseOverride
+func (m message) raw() string {
+	if m.Forms == nil {
+		return m.Text
+	}
+	data, _ := json.MarshalIndent(m.Forms, "", "  ")
+	return string(data)
+}
+
+// CheckOverride проверяет, что text может заменить перевод key языка lang: ключ
+// существует, шаблон разбирается, а параметры совпадают с файлом DefaultLanguage
+func (l *Localization) CheckOverride(lang, key, text string) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.files[lang] == nil {
+		return ErrUnknownLanguage
+	}
+	if !l.hasKeyLocked(key) {
+		return ErrUnknownKey
+	}
+	reference := l.files[DefaultLanguage][key]
+
+	msg := parseOverride(text)
+	if detail := formsMismatch(msg); detail != "" {
+		return errors.New(detail)
+	}
+	for _, form := range msg.texts() {
+		if !strings.Contains(form, "{{") {
+			continue
+		}
+		if _, err := template.New(key).Funcs(l.templateFuncs(lang)).Parse(form); err != nil {
+			return err
+		}
+	}
+	if detail := placeholderMismatch(reference, msg); detail != "" {
+		return errors.New(detail)
+	}
+	return nil
+}
+
+// HasKey сообщает, есть ли ключ в файле DefaultLanguage. Служебные ключи
+// не считаются переводами
+func (l *Localization) HasKey(key string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.hasKeyLocked(key)
+}
+
+func (l *Localization) hasKeyLocked(key string) bool {
+	_, ok := l.files[DefaultLanguage][key]
+	return ok && !strings.HasPrefix(key, "_")
+}
+
+// SetOverrides заменяет все тексты администраторов: lang → key → текст
+func (l *Localization) SetOverrides(texts map[string]map[string]string) {
+	overrides := make(map[string]map[string]message)
+	for lang, keys := range texts {
+		overrides[lang] = make(map[string]message)
+		for key, text := range keys {
+			overrides[lang][key] = parseOverride(text)
+		}
+	}
+
+	l.mu.Lock()
+	l.overrides = overrides
+	l.rebuild()
+	l.mu.Unlock()
+}
+
+// SetOverride заменяет перевод ключа поверх файлов. Текст нужно предварительно
+// проверить через CheckOverride
+func (l *Localization) SetOverride(lang, key, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.overrides[lang] == nil {
+		l.overrides[lang] = make(map[string]message)
+	}
+	l.overrides[lang][key] = parseOverride(text)
+	l.rebuild()
+}
+
+// RemoveOverride возвращает переводу ключа значение из файлов
+func (l *Localization) RemoveOverride(lang, key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.overrides[lang], key)
+	l.rebuild()
+}
+
+// Source возвращает перевод ключа из файлов и текст администратора, если он задан
+func (l *Localization) Source(lang, key string) (file string, override string, overridden bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if msg, ok := l.files[lang][key]; ok {
+		file = msg.raw()
+	}
+	if msg, ok := l.overrides[lang][key]; ok {
+		override, overridden = msg.raw(), true
+	}
+	return file, override, overridden
+}
+
+// Watch раз в interval проверяет файлы overrideDir и перечитывает переводы,
+// если они изменились. Встроенные переводы меняются только со сборкой бинарника
+func (l *Localization) Watch(interval time.Duration) {
+	if l.overrideDir == "" {
+		return
+	}
+
+	state := dirState(l.overrideDir)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		current := dirState(l.overrideDir)
+		if current == state {
+			continue
+		}
+		state = current
+
+		if err := l.Reload(); err != nil {
+			log.Printf("Failed to reload translations: %v", err)
+			continue
+		}
+		log.Printf("Translations reloaded from %s", l.overrideDir)
+		for _, problem := range l.Validate(nil) {
+			log.Printf("Translation problem: %s", problem)
+		}
+	}
+}
+
+// dirState описывает имена, размеры и время изменения файлов переводов каталога
+func dirState(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	sort.Strings(files)
+
+	var state strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&state, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+	}
+	return state.String()
+}
@@ -0,0 +1,110 @@
+package localization
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Категории множественного числа CLDR
+const (
+	PluralOne   = "one"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// pluralCategory возвращает категорию CLDR для числа n в языке lang.
+// Правила упрощены до целой части и признака дробного числа
+func pluralCategory(lang string, n float64) string {
+	integer := n == math.Trunc(n)
+	i := int64(math.Abs(n))
+
+	switch baseLanguage(lang) {
+	case "ru", "uk", "be":
+		if !integer {
+			return PluralOther
+		}
+		switch {
+		case i%10 == 1 && i%100 != 11:
+			return PluralOne
+		case slavicFew(i):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	case "pl":
+		if !integer {
+			return PluralOther
+		}
+		switch {
+		case i == 1:
+			return PluralOne
+		case slavicFew(i):
+			return PluralFew
+		default:
+			return PluralMany
+		}
+	case "cs", "sk":
+		if !integer {
+			return PluralMany
+		}
+		switch {
+		case i == 1:
+			return PluralOne
+		case i >= 2 && i <= 4:
+			return PluralFew
+		default:
+			return PluralOther
+		}
+	case "fr", "pt":
+		if i <= 1 {
+			return PluralOne
+		}
+		return PluralOther
+	case "ja", "ko", "zh", "vi", "th", "id":
+		return PluralOther
+	default:
+		if integer && i == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}
+}
+
+// slavicFew - 2-4, 22-24, ... кроме 12-14
+func slavicFew(i int64) bool {
+	return i%10 >= 2 && i%10 <= 4 && (i%100 < 12 || i%100 > 14)
+}
+
+// baseLanguage отбрасывает регион из кода языка: pt-BR → pt
+func baseLanguage(lang string) string {
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		return strings.ToLower(lang[:i])
+	}
+	return strings.ToLower(lang)
+}
+
+// toNumber приводит параметр перевода к числу для выбора формы множественного числа
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package localization
+
+import "testing"
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		lang string
+		n    float64
+		want string
+	}{
+		{"ru", 1, PluralOne},
+		{"ru", 21, PluralOne},
+		{"ru", 11, PluralMany},
+		{"ru", 2, PluralFew},
+		{"ru", 24, PluralFew},
+		{"ru", 12, PluralMany},
+		{"ru", 5, PluralMany},
+		{"ru", 0, PluralMany},
+		{"ru", 1.5, PluralOther},
+		{"uk-UA", 3, PluralFew},
+		{"pl", 1, PluralOne},
+		{"pl", 21, PluralMany},
+		{"pl", 22, PluralFew},
+		{"cs", 3, PluralFew},
+		{"cs", 5, PluralOther},
+		{"cs", 1.5, PluralMany},
+		{"fr", 0, PluralOne},
+		{"fr", 1.5, PluralOne},
+		{"fr", 2, PluralOther},
+		{"ja", 1, PluralOther},
+		{"en", 1, PluralOne},
+		{"en", 1.5, PluralOther},
+		{"en", 0, PluralOther},
+		{"en-US", 1, PluralOne},
+	}
+
+	for _, tt := range tests {
+		if got := pluralCategory(tt.lang, tt.n); got != tt.want {
+			t.Errorf("pluralCategory(%q, %v) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestGetPlural(t *testing.T) {
+	l, err := New("")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		lang  string
+		count interface{}
+		want  string
+	}{
+		{"ru", 1, "1 место"},
+		{"ru", 3, "3 места"},
+		{"ru", 11, "11 мест"},
+		{"ru", int64(21), "21 место"},
+		{"en", 1, "1 place"},
+		{"en", 2, "2 places"},
+	}
+
+	for _, tt := range tests {
+		if got := l.Get(tt.lang, "places_count", Args{"Count": tt.count}); got != tt.want {
+			t.Errorf("Get(%s, places_count, %v) = %q, want %q", tt.lang, tt.count, got, tt.want)
+		}
+	}
+}
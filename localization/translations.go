@@ -1,65 +1,389 @@
 package localization
 
 import (
+	"bytes"
+	"embed"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"log"
-	"path/filepath"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
 )
 
+// Args - именованные параметры перевода. Переводы с параметрами являются
+// шаблонами text/template: "Баланс: {{money .Balance}} USDT"
+type Args map[string]interface{}
+
+// Служебные ключи файлов переводов
+const (
+	nameKey     = "_name"     // название языка, которое показывается при выборе языка
+	fallbackKey = "_fallback" // язык, из которого берутся отсутствующие ключи
+	decimalKey  = "_decimal"  // разделитель дробной части сумм
+	groupKey    = "_group"    // разделитель разрядов сумм
+)
+
+// DefaultLanguage - последний язык в любой цепочке запасных языков
+const DefaultLanguage = "ru"
+
+// message - перевод ключа: строка или формы множественного числа
+// {"one": "...", "few": "...", "many": "...", "other": "..."}
+type message struct {
+	Text  string
+	Forms map[string]string
+}
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.Text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &m.Forms); err != nil {
+		return fmt.Errorf("translation must be a string or an object with plural forms")
+	}
+	if _, ok := m.Forms[PluralOther]; !ok {
+		return fmt.Errorf("plural forms must include %q", PluralOther)
+	}
+	return nil
+}
+
+// form возвращает текст для категории множественного числа, по умолчанию - other
+func (m message) form(category string) string {
+	if m.Forms == nil {
+		return m.Text
+	}
+	if text, ok := m.Forms[category]; ok {
+		return text
+	}
+	return m.Forms[PluralOther]
+}
+
 type Localization struct {
-	translations map[string]map[string]string
+	overrideDir string
+
+	mu           sync.RWMutex
+	files        map[string]map[string]message // встроенные переводы и файлы overrideDir
+	overrides    map[string]map[string]message // тексты, измененные администраторами
+	translations map[string]map[string]message // files, поверх которых наложены overrides
+
+	templatesMu sync.Mutex
+	templates   map[string]cachedTemplate // кэш разобранных шаблонов по lang + key + форма
 }
 
-func New() *Localization {
+type cachedTemplate struct {
+	text string // текст, из которого разобран шаблон: после Reload он может измениться
+	tmpl *template.Template
+}
+
+// Переводы встроены в бинарник, поэтому бот не зависит от рабочей директории
+//
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+// New загружает встроенные переводы. Файлы <lang>.json из overrideDir, если он задан,
+// заменяют отдельные ключи встроенных переводов или добавляют новые языки
+func New(overrideDir string) (*Localization, error) {
 	l := &Localization{
-		translations: make(map[string]map[string]string),
+		overrideDir: overrideDir,
+		overrides:   make(map[string]map[string]message),
+		templates:   make(map[string]cachedTemplate),
 	}
-	l.loadTranslations()
-	return l
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	for _, lang := range l.GetSupportedLanguages() {
+		if l.Name(lang) == lang {
+			log.Printf("Translation %s has no %s key, the language code is shown instead", lang, nameKey)
+		}
+	}
+	return l, nil
 }
 
-func (l *Localization) loadTranslations() {
-	languages := []string{"ru", "en"}
+// Reload заново читает встроенные переводы и файлы overrideDir. При ошибке
+// продолжают действовать ранее загруженные переводы
+func (l *Localization) Reload() error {
+	files := make(map[string]map[string]message)
+
+	embedded, err := readLocales(embeddedLocales, "locales")
+	if err != nil {
+		return fmt.Errorf("embedded translations: %w", err)
+	}
+	merge(files, embedded)
 
-	for _, lang := range languages {
-		filePath := filepath.Join("localization", "locales", fmt.Sprintf("%s.json", lang))
-		data, err := ioutil.ReadFile(filePath)
+	if l.overrideDir != "" {
+		overrides, err := readLocales(os.DirFS(l.overrideDir), ".")
 		if err != nil {
-			log.Fatalf("Failed to read translation file %s: %v", filePath, err)
+			return fmt.Errorf("%s: %w", l.overrideDir, err)
+		}
+		merge(files, overrides)
+	}
+
+	l.mu.Lock()
+	l.files = files
+	l.rebuild()
+	l.mu.Unlock()
+	return nil
+}
+
+// rebuild накладывает overrides на files и сбрасывает кэш шаблонов. Вызывается под l.mu
+func (l *Localization) rebuild() {
+	translations := make(map[string]map[string]message)
+	merge(translations, l.files)
+	merge(translations, l.overrides)
+	l.translations = translations
+
+	l.templatesMu.Lock()
+	l.templates = make(map[string]cachedTemplate)
+	l.templatesMu.Unlock()
+}
+
+// readLocales читает все файлы <lang>.json из каталога dir
+func readLocales(fsys fs.FS, dir string) (map[string]map[string]message, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	locales := make(map[string]map[string]message)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		filePath := path.Join(dir, entry.Name())
+		data, err := fs.ReadFile(fsys, filePath)
+		if err != nil {
+			return nil, err
 		}
 
-		var translations map[string]string
+		var translations map[string]message
 		if err := json.Unmarshal(data, &translations); err != nil {
-			log.Fatalf("Failed to parse translation file %s: %v", filePath, err)
+			return nil, fmt.Errorf("parse %s: %w", filePath, err)
+		}
+
+		locales[strings.TrimSuffix(entry.Name(), ".json")] = translations
+	}
+
+	return locales, nil
+}
+
+// merge копирует переводы из locales в dst, заменяя совпадающие ключи
+func merge(dst, locales map[string]map[string]message) {
+	for lang, translations := range locales {
+		if dst[lang] == nil {
+			dst[lang] = make(map[string]message)
+		}
+		for key, msg := range translations {
+			dst[lang][key] = msg
+		}
+	}
+}
+
+// fallbackChain возвращает языки, в которых ищется ключ: сам язык (или его базовый
+// язык для кодов вида pt-BR), затем цепочка _fallback и в конце DefaultLanguage
+func (l *Localization) fallbackChain(lang string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+
+	for code := lang; code != "" && !seen[code]; {
+		seen[code] = true
+		if l.translations[code] == nil {
+			code = baseLanguage(code)
+			continue
 		}
+		chain = append(chain, code)
+		code = l.translations[code][fallbackKey].Text
+	}
 
-		l.translations[lang] = translations
+	if !seen[DefaultLanguage] && l.translations[DefaultLanguage] != nil {
+		chain = append(chain, DefaultLanguage)
 	}
+	return chain
+}
+
+// lookup ищет ключ по цепочке запасных языков и возвращает язык, в котором он найден
+func (l *Localization) lookup(lang, key string) (message, string, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lookupLocked(lang, key)
 }
 
-func (l *Localization) Get(lang, key string, args ...interface{}) string {
-	if l.translations[lang] == nil {
-		lang = "ru" // Default fallback
+// lookupLocked - lookup для вызова под l.mu
+func (l *Localization) lookupLocked(lang, key string) (message, string, bool) {
+	for _, code := range l.fallbackChain(lang) {
+		if msg, ok := l.translations[code][key]; ok {
+			return msg, code, true
+		}
 	}
+	return message{}, "", false
+}
 
-	template, exists := l.translations[lang][key]
+// Get возвращает перевод ключа, подставляя в шаблон параметры args, если они переданы.
+// Формы множественного числа выбираются по параметру Count
+func (l *Localization) Get(lang, key string, args ...Args) string {
+	msg, resolved, exists := l.lookup(lang, key)
 	if !exists {
 		return fmt.Sprintf("Missing translation: %s.%s", lang, key)
 	}
 
+	var data Args
 	if len(args) > 0 {
-		return fmt.Sprintf(template, args...)
+		data = args[0]
+	}
+
+	category := ""
+	if msg.Forms != nil {
+		n, _ := toNumber(data["Count"])
+		category = pluralCategory(resolved, n)
+	}
+	text := msg.form(category)
+
+	// Вложенные переводы и суммы в шаблоне форматируются для запрошенного языка,
+	// даже если сам шаблон взят из запасного
+	if data != nil {
+		return l.execute(lang, key, category, text, data)
+	}
+	return text
+}
+
+// execute подставляет именованные параметры в шаблон перевода
+func (l *Localization) execute(lang, key, category, text string, data Args) string {
+	tmpl, err := l.template(lang, key, category, text)
+	if err != nil {
+		log.Printf("Invalid translation template %s.%s: %v", lang, key, err)
+		return text
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("Error executing translation %s.%s: %v", lang, key, err)
+		return text
+	}
+	return buf.String()
+}
+
+func (l *Localization) template(lang, key, category, text string) (*template.Template, error) {
+	cacheKey := lang + "." + key
+	if category != "" {
+		cacheKey += "." + category
+	}
+
+	l.templatesMu.Lock()
+	defer l.templatesMu.Unlock()
+
+	if cached, ok := l.templates[cacheKey]; ok && cached.text == text {
+		return cached.tmpl, nil
 	}
 
-	return template
+	// Отсутствующий параметр - ошибка перевода, а не пустая строка в сообщении
+	tmpl, err := template.New(cacheKey).Funcs(l.templateFuncs(lang)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	l.templates[cacheKey] = cachedTemplate{text: text, tmpl: tmpl}
+	return tmpl, nil
+}
+
+// templateFuncs возвращает функции, доступные в шаблонах переводов языка lang
+func (l *Localization) templateFuncs(lang string) template.FuncMap {
+	return template.FuncMap{
+		// {{money .Balance}} - сумма с двумя знаками и разделителями языка
+		"money": func(amount float64) string {
+			return l.FormatAmount(lang, amount)
+		},
+		// {{plural "users_count" .Total}} - вложенный перевод с формой для числа
+		"plural": func(key string, count interface{}) string {
+			return l.Get(lang, key, Args{"Count": count})
+		},
+	}
 }
 
+// FormatAmount форматирует сумму с двумя знаками после запятой, используя
+// разделители _decimal и _group языка: 1 234,50 для ru и 1,234.50 для en
+func (l *Localization) FormatAmount(lang string, amount float64) string {
+	decimal, group := ".", ""
+	if msg, _, ok := l.lookup(lang, decimalKey); ok {
+		decimal = msg.Text
+	}
+	if msg, _, ok := l.lookup(lang, groupKey); ok {
+		group = msg.Text
+	}
+
+	formatted := strconv.FormatFloat(math.Abs(amount), 'f', 2, 64)
+	integer, fraction := formatted[:len(formatted)-3], formatted[len(formatted)-2:]
+
+	var b strings.Builder
+	if amount < 0 && formatted != "0.00" {
+		b.WriteByte('-')
+	}
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(digit)
+	}
+	b.WriteString(decimal)
+	b.WriteString(fraction)
+
+	return b.String()
+}
+
+// GetSupportedLanguages возвращает коды всех загруженных языков в алфавитном порядке
 func (l *Localization) GetSupportedLanguages() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
 	var languages []string
 	for lang := range l.translations {
 		languages = append(languages, lang)
 	}
+	sort.Strings(languages)
 	return languages
 }
+
+// Has сообщает, загружен ли перевод для языка
+func (l *Localization) Has(lang string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.translations[lang] != nil
+}
+
+// Match подбирает загруженный язык для кода языка клиента Telegram: en-US → en.
+// Возвращает false, если подходящего перевода нет
+func (l *Localization) Match(code string) (string, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return "", false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for lang := range l.translations {
+		if strings.ToLower(lang) == code {
+			return lang, true
+		}
+	}
+	for lang := range l.translations {
+		if strings.ToLower(lang) == baseLanguage(code) {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// Name возвращает название языка из ключа _name или его код
+func (l *Localization) Name(lang string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if name := l.translations[lang][nameKey].Text; name != "" {
+		return name
+	}
+	return lang
+}
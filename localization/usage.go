@@ -0,0 +1,113 @@
+package localization
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// KeyUsage - ключ перевода, найденный в исходном коде
+type KeyUsage struct {
+	Key    string
+	Prefix bool   // ключ собирается в коде: "metric_"+contest.Metric
+	Pos    string // файл:строка
+}
+
+// defined сообщает, есть ли ключ (или хотя бы один ключ с таким префиксом) в переводах
+func (u KeyUsage) defined(translations map[string]message) bool {
+	if !u.Prefix {
+		_, ok := translations[u.Key]
+		return ok
+	}
+	for key := range translations {
+		if strings.HasPrefix(key, u.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// UsedKeys находит в .go файлах каталога dir вызовы loc.Get с ключом-литералом или
+// литеральным префиксом ключа. Ключи из переменных пропускаются
+func UsedKeys(dir string) ([]KeyUsage, error) {
+	var usages []KeyUsage
+	fset := token.NewFileSet()
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) < 2 || !isLocalizationGet(call.Fun) {
+				return true
+			}
+			if usage, ok := keyArgument(call.Args[1]); ok {
+				usage.Pos = fset.Position(call.Args[1].Pos()).String()
+				usages = append(usages, usage)
+			}
+			return true
+		})
+		return nil
+	})
+
+	return usages, err
+}
+
+// isLocalizationGet распознает loc.Get(...) и h.loc.Get(...)
+func isLocalizationGet(fun ast.Expr) bool {
+	selector, ok := fun.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != "Get" {
+		return false
+	}
+	switch x := selector.X.(type) {
+	case *ast.Ident:
+		return x.Name == "loc"
+	case *ast.SelectorExpr:
+		return x.Sel.Name == "loc"
+	}
+	return false
+}
+
+func keyArgument(arg ast.Expr) (KeyUsage, bool) {
+	switch expr := arg.(type) {
+	case *ast.BasicLit:
+		if key, ok := stringLiteral(expr); ok {
+			return KeyUsage{Key: key}, true
+		}
+	case *ast.BinaryExpr:
+		if literal, ok := expr.X.(*ast.BasicLit); ok && expr.Op == token.ADD {
+			if prefix, ok := stringLiteral(literal); ok {
+				return KeyUsage{Key: prefix, Prefix: true}, true
+			}
+		}
+	}
+	return KeyUsage{}, false
+}
+
+func stringLiteral(lit *ast.BasicLit) (string, bool) {
+	if lit.Kind != token.STRING {
+		return "", false
+	}
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
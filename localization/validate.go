@@ -0,0 +1,207 @@
+package localization
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Виды проблем, которые находит Validate
+const (
+	ProblemMissingKey   = "missing_key"  // ключ есть в другом языке, но отсутствует в этом
+	ProblemPlaceholders = "placeholders" // параметры перевода не совпадают с DefaultLanguage
+	ProblemTemplate     = "template"     // шаблон перевода не разбирается
+	ProblemUnknownKey   = "unknown_key"  // ключ используется в коде, но не переведен
+)
+
+// Problem - одна найденная проблема переводов
+type Problem struct {
+	Kind   string
+	Lang   string // пустой для проблем, не привязанных к языку
+	Key    string
+	Detail string
+}
+
+func (p Problem) String() string {
+	location := p.Key
+	if p.Lang != "" {
+		location = p.Lang + "." + p.Key
+	}
+	if p.Detail == "" {
+		return fmt.Sprintf("%s: %s", p.Kind, location)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Kind, location, p.Detail)
+}
+
+var (
+	// %d, %.2f, %[1]s - без %%, который не является параметром
+	printfVerbRe = regexp.MustCompile(`%[-+# 0]*(?:\[\d+\])?(?:\d+|\*)?(?:\.(?:\d+|\*)?)?[a-zA-Z%]`)
+	// {{.Balance}}, {{money .Balance}}, {{with .WelcomeBonus}}
+	templateFieldRe = regexp.MustCompile(`\.([A-Za-z_][A-Za-z0-9_]*)`)
+	templateActRe   = regexp.MustCompile(`\{\{.*?\}\}`)
+	// {{plural "users_count" .Count}}
+	templatePluralRe = regexp.MustCompile(`plural\s+"([^"]+)"`)
+)
+
+// Validate проверяет, что во всех языках одинаковый набор ключей, что параметры
+// переводов совпадают с DefaultLanguage и что шаблоны разбираются. Ключи из used
+// (см. UsedKeys) дополнительно проверяются на наличие в DefaultLanguage.
+// Служебные ключи, начинающиеся с "_", не проверяются
+func (l *Localization) Validate(used []KeyUsage) []Problem {
+	var problems []Problem
+	languages := l.GetSupportedLanguages()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	keys := make(map[string]bool)
+	for _, lang := range languages {
+		for key := range l.translations[lang] {
+			if !strings.HasPrefix(key, "_") {
+				keys[key] = true
+			}
+		}
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	reference := l.translations[DefaultLanguage]
+	for _, lang := range languages {
+		for _, key := range sortedKeys {
+			msg, ok := l.translations[lang][key]
+			if !ok {
+				problems = append(problems, Problem{Kind: ProblemMissingKey, Lang: lang, Key: key})
+				continue
+			}
+
+			problems = append(problems, l.checkTemplates(lang, key, msg)...)
+
+			refMsg, ok := reference[key]
+			if lang == DefaultLanguage || !ok {
+				continue
+			}
+			if detail := placeholderMismatch(refMsg, msg); detail != "" {
+				problems = append(problems, Problem{Kind: ProblemPlaceholders, Lang: lang, Key: key, Detail: detail})
+			}
+		}
+	}
+
+	for _, usage := range used {
+		if !usage.defined(reference) {
+			problems = append(problems, Problem{Kind: ProblemUnknownKey, Key: usage.Key, Detail: "used at " + usage.Pos})
+		}
+	}
+
+	return problems
+}
+
+// checkTemplates разбирает шаблоны всех форм перевода и проверяет ключи вложенных plural.
+// Вызывается под l.mu
+func (l *Localization) checkTemplates(lang, key string, msg message) []Problem {
+	var problems []Problem
+	if detail := formsMismatch(msg); detail != "" {
+		problems = append(problems, Problem{Kind: ProblemTemplate, Lang: lang, Key: key, Detail: detail})
+	}
+	for _, text := range msg.texts() {
+		if !strings.Contains(text, "{{") {
+			continue
+		}
+		if _, err := template.New(key).Funcs(l.templateFuncs(lang)).Parse(text); err != nil {
+			problems = append(problems, Problem{Kind: ProblemTemplate, Lang: lang, Key: key, Detail: err.Error()})
+			continue
+		}
+		for _, match := range templatePluralRe.FindAllStringSubmatch(text, -1) {
+			if _, _, ok := l.lookupLocked(lang, match[1]); !ok {
+				problems = append(problems, Problem{Kind: ProblemTemplate, Lang: lang, Key: key,
+					Detail: fmt.Sprintf("plural key %q does not exist", match[1])})
+			}
+		}
+	}
+	return problems
+}
+
+// formsMismatch проверяет, что формы множественного числа - шаблоны: форма выбирается
+// по параметру Count из Args, fmt-аргументы в нее не передаются
+func formsMismatch(msg message) string {
+	if msg.Forms == nil {
+		return ""
+	}
+	for _, text := range msg.texts() {
+		if verbs := printfVerbs(text); verbs != "" {
+			return fmt.Sprintf("plural forms must be templates with {{.Count}}, got verbs %q", verbs)
+		}
+	}
+	return ""
+}
+
+// placeholderMismatch сравнивает параметры перевода с эталонным. fmt-глаголы должны
+// совпадать по порядку в каждой форме, поля шаблонов - по набору
+func placeholderMismatch(reference, msg message) string {
+	refVerbs := printfVerbs(reference.form(PluralOther))
+	for _, text := range msg.texts() {
+		if verbs := printfVerbs(text); verbs != refVerbs {
+			return fmt.Sprintf("verbs %q, expected %q", verbs, refVerbs)
+		}
+	}
+
+	refFields, fields := templateFields(reference), templateFields(msg)
+	if refFields != fields {
+		return fmt.Sprintf("fields %q, expected %q", fields, refFields)
+	}
+	return ""
+}
+
+// printfVerbs возвращает буквы fmt-глаголов строки: "%d %.2f" → "df"
+func printfVerbs(text string) string {
+	if strings.Contains(text, "{{") {
+		return ""
+	}
+	var verbs strings.Builder
+	for _, verb := range printfVerbRe.FindAllString(text, -1) {
+		if verb != "%%" {
+			verbs.WriteByte(verb[len(verb)-1])
+		}
+	}
+	return verbs.String()
+}
+
+// templateFields возвращает отсортированные имена полей, используемых во всех формах
+func templateFields(msg message) string {
+	set := make(map[string]bool)
+	for _, text := range msg.texts() {
+		for _, action := range templateActRe.FindAllString(text, -1) {
+			for _, match := range templateFieldRe.FindAllStringSubmatch(action, -1) {
+				set[match[1]] = true
+			}
+		}
+	}
+	fields := make([]string, 0, len(set))
+	for field := range set {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ",")
+}
+
+// texts возвращает тексты всех форм перевода
+func (m message) texts() []string {
+	if m.Forms == nil {
+		return []string{m.Text}
+	}
+	categories := make([]string, 0, len(m.Forms))
+	for category := range m.Forms {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	texts := make([]string, 0, len(categories))
+	for _, category := range categories {
+		texts = append(texts, m.Forms[category])
+	}
+	return texts
+}
@@ -0,0 +1,143 @@
+package localization
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Встроенные переводы должны проходить проверку, которую выполняет -check-locales
+func TestValidateEmbedded(t *testing.T) {
+	l, err := New("")
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	used, err := UsedKeys("../")
+	if err != nil {
+		t.Fatalf("UsedKeys: %v", err)
+	}
+	if len(used) == 0 {
+		t.Fatal("UsedKeys found no loc.Get calls")
+	}
+
+	for _, problem := range l.Validate(used) {
+		t.Error(problem)
+	}
+}
+
+func TestValidateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	writeLocale(t, dir, "en.json", `{
+		"balance_display": "Balance: {{money .Balance}} {{.Bonus}} USDT",
+		"top_contest_title": "{{.Metric}} {{money .Prize}} {{plural \"places_count\" .Count}} {{.EndsAt}}"
+	}`)
+	writeLocale(t, dir, "de.json", `{"_name": "Deutsch", "balance_display": "Guthaben: {{money .Balance}} USDT"}`)
+	l, err := New(dir)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	problems := l.Validate([]KeyUsage{{Key: "no_such_key", Pos: "main.go:1"}})
+
+	want := []Problem{
+		{Kind: ProblemPlaceholders, Lang: "en", Key: "balance_display"},
+		{Kind: ProblemPlaceholders, Lang: "en", Key: "top_contest_title"},
+		{Kind: ProblemMissingKey, Lang: "de", Key: "withdraw_prompt"},
+		{Kind: ProblemUnknownKey, Key: "no_such_key"},
+	}
+	for _, w := range want {
+		if !hasProblem(problems, w) {
+			t.Errorf("no %s problem for %s.%s in %v", w.Kind, w.Lang, w.Key, problems)
+		}
+	}
+	if hasProblem(problems, Problem{Kind: ProblemPlaceholders, Lang: "de", Key: "balance_display"}) {
+		t.Errorf("unexpected placeholders problem for de.balance_display")
+	}
+}
+
+func TestPlaceholderMismatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		reference message
+		msg       message
+		mismatch  bool
+	}{
+		{"same verbs", message{Text: "Баланс: %s USDT"}, message{Text: "Balance: %s USDT"}, false},
+		{"escaped percent", message{Text: "%d%% готово"}, message{Text: "%d%% done"}, false},
+		{"extra verb", message{Text: "Баланс: %s"}, message{Text: "Balance: %s (%d)"}, true},
+		{"missing verb", message{Text: "%d из %d"}, message{Text: "%d"}, true},
+		{"reordered verbs", message{Text: "%d: %s"}, message{Text: "%s: %d"}, true},
+		{"same fields", message{Text: "{{.UserID}} {{money .Balance}}"}, message{Text: "{{money .Balance}} {{.UserID}}"}, false},
+		{"missing field", message{Text: "{{.UserID}} {{.Balance}}"}, message{Text: "{{.UserID}}"}, true},
+		{"renamed field", message{Text: "{{.Count}} мест"}, message{Text: "{{.Places}} places"}, true},
+		{
+			"plural fields",
+			message{Forms: map[string]string{"one": "{{.Count}} место", "few": "{{.Count}} места", "other": "{{.Count}} мест"}},
+			message{Forms: map[string]string{"one": "{{.Count}} place", "other": "{{.Count}} places"}},
+			false,
+		},
+		{
+			"plural form field",
+			message{Forms: map[string]string{"one": "{{.Count}} место", "other": "{{.Count}} мест"}},
+			message{Forms: map[string]string{"one": "one place", "other": "{{.Count}} places in {{.Contest}}"}},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := placeholderMismatch(tt.reference, tt.msg); (got != "") != tt.mismatch {
+			t.Errorf("%s: placeholderMismatch = %q, want mismatch %v", tt.name, got, tt.mismatch)
+		}
+	}
+}
+
+func TestPrintfVerbs(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"без параметров", ""},
+		{"%d пользователей, %s", "ds"},
+		{"%.2f USDT", "f"},
+		{"%+.2f", "f"},
+		{"%[2]s %[1]d", "sd"},
+		{"100%% готово", ""},
+		{"%d%%", "d"},
+		{"{{.Count}} - 100%", ""},
+	}
+
+	for _, tt := range tests {
+		if got := printfVerbs(tt.text); got != tt.want {
+			t.Errorf("printfVerbs(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFormsMismatch(t *testing.T) {
+	if detail := formsMismatch(message{Forms: map[string]string{"one": "%d место", "other": "%d мест"}}); detail == "" {
+		t.Error("formsMismatch accepted plural forms with fmt verbs")
+	}
+	if detail := formsMismatch(message{Forms: map[string]string{"one": "{{.Count}} место", "other": "{{.Count}} мест"}}); detail != "" {
+		t.Errorf("formsMismatch = %q for template forms", detail)
+	}
+	if detail := formsMismatch(message{Text: "%d мест"}); detail != "" {
+		t.Errorf("formsMismatch = %q for a plain string", detail)
+	}
+}
+
+func writeLocale(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hasProblem(problems []Problem, want Problem) bool {
+	for _, p := range problems {
+		if p.Kind == want.Kind && p.Lang == want.Lang && p.Key == want.Key {
+			return true
+		}
+	}
+	return false
+}
@@ -1,22 +1,49 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
 	"strings"
 	"telegram-bot/config"
 	"telegram-bot/database"
 	"telegram-bot/handlers"
 	"telegram-bot/localization"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func main() {
+	configFile := flag.String("config", "", "YAML configuration file (default: $CONFIG_FILE or "+config.DefaultFile+" if present)")
+	checkConfig := flag.Bool("check-config", false, "validate the configuration, then exit")
+	checkLocales := flag.Bool("check-locales", false, "validate translations and keys used in the Go sources of the current directory, then exit")
+	flag.Parse()
+
+	// Проверка переводов для CI: не требует токена и базы
+	if *checkLocales {
+		os.Exit(runLocalesCheck(os.Getenv("LOCALES_DIR"), "."))
+	}
+
 	// Загружаем конфигурацию
-	cfg := config.Load()
+	cfg, err := config.Load(*configFile)
+	if *checkConfig {
+		if err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		log.Println("Configuration is valid")
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Инициализируем локализацию
-	loc := localization.New()
+	loc, err := localization.New(cfg.LocalesDir)
+	if err != nil {
+		log.Fatalf("Failed to load translations: %v", err)
+	}
 
 	// Подключаемся к базе данных
 	db, err := database.New(cfg.DatabaseFile)
@@ -25,6 +52,31 @@ func main() {
 	}
 	defer db.Close()
 
+	// Тексты, измененные администраторами, накладываются поверх файлов переводов
+	overrides, err := db.TextOverrides()
+	if err != nil {
+		log.Fatalf("Failed to load text overrides: %v", err)
+	}
+	loc.SetOverrides(overrides)
+
+	// В бинарнике нет исходников, поэтому при запуске проверяются только сами переводы
+	if problems := loc.Validate(nil); len(problems) > 0 {
+		for _, problem := range problems {
+			log.Printf("Translation problem: %s", problem)
+		}
+		if cfg.LocalesStrict {
+			log.Fatalf("Found %d translation problems, refusing to start (LOCALES_STRICT=true)", len(problems))
+		}
+	}
+
+	// Назначаем первых владельцев из ADMIN_IDS, если в базе их еще нет
+	if err := db.BootstrapOwners(cfg.AdminUserIDs); err != nil {
+		log.Fatalf("Failed to bootstrap admin owners: %v", err)
+	}
+	if owners, err := db.CountOwners(); err != nil || owners == 0 {
+		log.Fatal("No bot owners configured: set ADMIN_IDS (comma-separated list of Telegram user IDs)")
+	}
+
 	// Создаем бота
 	bot, err := tgbotapi.NewBotAPI(cfg.BotToken)
 	if err != nil {
@@ -41,14 +93,33 @@ func main() {
 	// Настраиваем получение обновлений
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
+	u.AllowedUpdates = []string{
+		tgbotapi.UpdateTypeMessage,
+		tgbotapi.UpdateTypeCallbackQuery,
+		tgbotapi.UpdateTypeMyChatMember,
+	}
 
 	updates := bot.GetUpdatesChan(u)
 
+	// Возвращаем отправителям суммы просроченных подарочных ваучеров
+	go userHandler.RunVoucherExpiry(time.Minute)
+
+	// Ежедневный отчет о подозрительных реферерах
+	go adminHandler.RunFraudReport(24 * time.Hour)
+
+	// Закрываем завершившиеся конкурсы и начисляем призы
+	go adminHandler.RunContests(time.Minute)
+
+	// Перечитываем переводы из LOCALES_DIR после правки файлов
+	go loc.Watch(5 * time.Second)
+
 	log.Println("Bot started successfully! Waiting for messages...")
 
 	// Основной цикл обработки сообщений
 	for update := range updates {
 		if update.Message != nil {
+			go userHandler.TrackActivity(update.Message.From)
+
 			// Обрабатываем команды
 			if update.Message.IsCommand() {
 				switch update.Message.Command() {
@@ -56,6 +127,22 @@ func main() {
 					go userHandler.HandleStart(update)
 				case "admin":
 					go adminHandler.HandleAdminCommand(update)
+				case "user":
+					go adminHandler.HandleUserCommand(update)
+				case "audit":
+					go adminHandler.HandleAuditCommand(update)
+				case "top":
+					go userHandler.HandleTopCommand(update)
+				case "language":
+					go userHandler.HandleLanguageCommand(update)
+				case "contest":
+					go adminHandler.HandleContestCommand(update)
+				case "campaign":
+					go adminHandler.HandleCampaignCommand(update)
+				case "tree":
+					go adminHandler.HandleTreeCommand(update)
+				case "text":
+					go adminHandler.HandleTextCommand(update)
 				case "cancel":
 					go userHandler.HandleMessage(update)
 					go adminHandler.HandleMessage(update)
@@ -66,6 +153,8 @@ func main() {
 				go adminHandler.HandleMessage(update)
 			}
 		} else if update.CallbackQuery != nil {
+			go userHandler.TrackActivity(update.CallbackQuery.From)
+
 			// Обрабатываем нажатия на кнопки
 			if strings.HasPrefix(update.CallbackQuery.Data, "lang_") {
 				go userHandler.HandleLanguageSelection(update.CallbackQuery)
@@ -76,26 +165,63 @@ func main() {
 			} else if isAdminCallback(update.CallbackQuery.Data) {
 				go adminHandler.HandleAdminCallback(update.CallbackQuery)
 			}
+		} else if update.MyChatMember != nil {
+			// Пользователь заблокировал или разблокировал бота
+			go userHandler.HandleMyChatMember(update.MyChatMember)
 		}
 	}
 }
 
+// runLocalesCheck выводит проблемы переводов и ключей из исходников srcDir и
+// возвращает код завершения: 1, если проблемы найдены
+func runLocalesCheck(localesDir, srcDir string) int {
+	loc, err := localization.New(localesDir)
+	if err != nil {
+		log.Printf("Failed to load translations: %v", err)
+		return 1
+	}
+
+	used, err := localization.UsedKeys(srcDir)
+	if err != nil {
+		log.Printf("Failed to scan sources for translation keys: %v", err)
+		return 1
+	}
+
+	problems := loc.Validate(used)
+	for _, problem := range problems {
+		log.Println(problem)
+	}
+	if len(problems) > 0 {
+		log.Printf("Found %d translation problems", len(problems))
+		return 1
+	}
+
+	log.Printf("Translations are consistent, %d key usages checked", len(used))
+	return 0
+}
+
 func isUserCallback(data string) bool {
-	userCallbacks := []string{"user_balance", "user_withdraw", "user_gift", "user_referral"}
+	userCallbacks := []string{"user_balance", "user_withdraw", "user_gift", "user_referral", "user_ref_codes", "user_ref_code_new", "user_language", "sub_check"}
 	for _, callback := range userCallbacks {
 		if data == callback {
 			return true
 		}
 	}
-	return false
+	return strings.HasPrefix(data, "gift_") || strings.HasPrefix(data, "captcha_") || strings.HasPrefix(data, "user_refs_")
 }
 
 func isAdminCallback(data string) bool {
-	adminCallbacks := []string{"admin_user_count", "admin_stats", "admin_db_download", "admin_mass_message", "admin_change_balance"}
+	adminCallbacks := []string{"admin_user_count", "admin_stats", "admin_campaigns", "admin_db_download", "admin_mass_message", "admin_change_balance", "admin_user_search", "admin_balance_confirm", "admin_balance_cancel"}
 	for _, callback := range adminCallbacks {
 		if data == callback {
 			return true
 		}
 	}
+	adminCallbackPrefixes := []string{"admin_card_", "admin_audit", "admin_wd_", "admin_fraud_", "admin_text_", "admin_adm", "admin_role"}
+	for _, prefix := range adminCallbackPrefixes {
+		if strings.HasPrefix(data, prefix) {
+			return true
+		}
+	}
 	return false
 }
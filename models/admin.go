@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Права администраторов
+const (
+	PermViewStats          = "view_stats"
+	PermExportDB           = "export_db"
+	PermBroadcast          = "broadcast"
+	PermAdjustBalance      = "adjust_balance"
+	PermApproveWithdrawals = "approve_withdrawals"
+	PermManageUsers        = "manage_users"
+	PermManageAdmins       = "manage_admins"
+	PermViewAudit          = "view_audit"
+	PermManageContests     = "manage_contests"
+	PermManageCampaigns    = "manage_campaigns"
+	PermManageTexts        = "manage_texts"
+)
+
+// AllPermissions задает порядок отображения прав в интерфейсе
+var AllPermissions = []string{
+	PermViewStats,
+	PermExportDB,
+	PermBroadcast,
+	PermAdjustBalance,
+	PermApproveWithdrawals,
+	PermManageUsers,
+	PermManageContests,
+	PermManageCampaigns,
+	PermManageTexts,
+	PermViewAudit,
+	PermManageAdmins,
+}
+
+// Роли администраторов
+const (
+	RoleOwner    = "owner"
+	RoleFinance  = "finance"
+	RoleSupport  = "support"
+	RoleMarketer = "marketer"
+)
+
+// DefaultRoles - набор прав, с которым роли создаются в новой базе
+var DefaultRoles = []Role{
+	{Name: RoleOwner, Permissions: AllPermissions},
+	{Name: RoleFinance, Permissions: []string{PermViewStats, PermAdjustBalance, PermApproveWithdrawals, PermManageUsers}},
+	{Name: RoleSupport, Permissions: []string{PermViewStats, PermManageUsers}},
+	{Name: RoleMarketer, Permissions: []string{PermViewStats, PermBroadcast, PermManageContests, PermManageCampaigns, PermManageTexts}},
+}
+
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+func (r *Role) Has(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type Admin struct {
+	UserID    int64     `json:"user_id"`
+	Role      Role      `json:"role"`
+	GrantedBy *int64    `json:"granted_by"`
+	GrantedAt time.Time `json:"granted_at"`
+}
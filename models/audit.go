@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Действия администраторов, попадающие в журнал аудита
+const (
+	AuditAccessDenied   = "access_denied"
+	AuditOpenMenu       = "open_menu"
+	AuditViewUserCount  = "view_user_count"
+	AuditViewStats      = "view_stats"
+	AuditDBDownload     = "db_download"
+	AuditBroadcast      = "broadcast"
+	AuditBalanceAdjust  = "balance_adjust"
+	AuditUserLookup     = "user_lookup"
+	AuditUserRestrict   = "user_restriction"
+	AuditDirectMessage  = "direct_message"
+	AuditViewAuditLog   = "view_audit_log"
+	AuditExportAuditLog = "export_audit_log"
+
+	AuditAdminRoleSet      = "admin_role_set"
+	AuditAdminRemove       = "admin_remove"
+	AuditRolePermission    = "role_permission"
+	AuditWithdrawalApprove = "withdrawal_approve"
+	AuditWithdrawalReject  = "withdrawal_reject"
+	AuditReferralRelease   = "referral_release"
+	AuditReferralReject    = "referral_reject"
+	AuditContestStart      = "contest_start"
+	AuditContestCancel     = "contest_cancel"
+	AuditCampaignCreate    = "campaign_create"
+	AuditTextOverride      = "text_override"
+	AuditTextRevert        = "text_revert"
+)
+
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	ActorID   int64                  `json:"actor_id"`
+	Action    string                 `json:"action"`
+	TargetID  *int64                 `json:"target_id"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditFilter ограничивает выборку из журнала аудита; нулевые поля не фильтруют
+type AuditFilter struct {
+	ActorID  int64
+	Action   string
+	TargetID int64
+	Since    time.Time
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Campaign - рекламная кампания со ссылкой ?start=c_<slug>
+type Campaign struct {
+	Slug string `json:"slug"`
+	// Пользователь, которому засчитываются пришедшие по ссылке как рефералы
+	OwnerID *int64 `json:"owner_id"`
+	// Награда владельцу вместо Config.RewardAmount; nil - по умолчанию
+	RewardAmount *float64 `json:"reward_amount"`
+	// Бонус новому пользователю при регистрации по ссылке
+	WelcomeBonus float64   `json:"welcome_bonus"`
+	CreatedBy    int64     `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CampaignStats - результаты кампании
+type CampaignStats struct {
+	Campaign
+	Signups        int     `json:"signups"`
+	Confirmed      int     `json:"confirmed"`
+	Withdrawals    int     `json:"withdrawals"`
+	WithdrawnTotal float64 `json:"withdrawn_total"`
+	// Выплаченные за пользователей кампании реферальные награды и бонусы
+	Cost float64 `json:"cost"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Метрики рейтинга
+const (
+	MetricReferrals = "referrals" // подтвержденные рефералы
+	MetricEarned    = "earned"    // сумма реферальных наград
+)
+
+// Статусы конкурса
+const (
+	ContestActive    = "active"
+	ContestFinished  = "finished"
+	ContestCancelled = "cancelled"
+)
+
+type Contest struct {
+	ID        int64     `json:"id"`
+	Metric    string    `json:"metric"`
+	PrizePool float64   `json:"prize_pool"`
+	Winners   int       `json:"winners"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Status    string    `json:"status"`
+	CreatedBy int64     `json:"created_by"`
+}
+
+// RankEntry - строка рейтинга
+type RankEntry struct {
+	Rank   int     `json:"rank"`
+	UserID int64   `json:"user_id"`
+	Value  float64 `json:"value"`
+}
+
+// ContestPrize - приз, начисленный победителю конкурса
+type ContestPrize struct {
+	RankEntry
+	Amount float64 `json:"amount"`
+}
+
+// PrizeShares делит призовой фонд между победителями по убыванию мест:
+// при трех победителях доли 3/6, 2/6 и 1/6
+func PrizeShares(pool float64, winners int) []float64 {
+	total := winners * (winners + 1) / 2
+	shares := make([]float64, winners)
+	for i := range shares {
+		shares[i] = pool * float64(winners-i) / float64(total)
+	}
+	return shares
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPrizeShares(t *testing.T) {
+	tests := []struct {
+		pool    float64
+		winners int
+		want    []float64
+	}{
+		{60, 1, []float64{60}},
+		{60, 2, []float64{40, 20}},
+		{60, 3, []float64{30, 20, 10}},
+		{100, 4, []float64{40, 30, 20, 10}},
+	}
+
+	for _, tt := range tests {
+		shares := PrizeShares(tt.pool, tt.winners)
+		if len(shares) != len(tt.want) {
+			t.Fatalf("PrizeShares(%v, %d) = %v, want %v", tt.pool, tt.winners, shares, tt.want)
+		}
+		var total float64
+		for i := range shares {
+			if math.Abs(shares[i]-tt.want[i]) > 1e-9 {
+				t.Errorf("PrizeShares(%v, %d) = %v, want %v", tt.pool, tt.winners, shares, tt.want)
+				break
+			}
+			total += shares[i]
+		}
+		if math.Abs(total-tt.pool) > 1e-9 {
+			t.Errorf("PrizeShares(%v, %d) sum = %v, want the whole pool", tt.pool, tt.winners, total)
+		}
+	}
+}
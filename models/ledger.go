@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Типы записей в журнале движения средств
+const (
+	LedgerReferralReward = "referral_reward"
+	LedgerWithdrawal     = "withdrawal"
+	LedgerAdminAdjust    = "admin_adjustment"
+	LedgerWithdrawRefund = "withdrawal_refund"
+	LedgerGiftSent       = "gift_sent"
+	LedgerGiftReceived   = "gift_received"
+	LedgerVoucherCreated = "voucher_created"
+	LedgerVoucherClaimed = "voucher_claimed"
+	LedgerVoucherRefund  = "voucher_refund"
+	LedgerContestPrize   = "contest_prize"
+	LedgerWelcomeBonus   = "welcome_bonus"
+)
+
+// Статусы заявок на вывод
+const (
+	WithdrawalPending  = "pending"
+	WithdrawalApproved = "approved"
+	WithdrawalRejected = "rejected"
+)
+
+type LedgerEntry struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	Kind         string    `json:"kind"`
+	Amount       float64   `json:"amount"`
+	BalanceAfter float64   `json:"balance_after"`
+	RefUserID    *int64    `json:"ref_user_id"`
+	ActorID      *int64    `json:"actor_id"`
+	Note         string    `json:"note"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ReferralReward - награда, начисленная одному из рефереров цепочки
+type ReferralReward struct {
+	UserID int64
+	Level  int // 1 - прямой реферер
+	Amount float64
+}
+
+type Withdrawal struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Amount    float64   `json:"amount"`
+	Wallet    string    `json:"wallet"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// Статусы реферала: награда начисляется только после подтверждения.
+// Подозрительные рефералы задерживаются до решения администратора
+const (
+	ReferralPending   = "pending"
+	ReferralConfirmed = "confirmed"
+	ReferralHeld      = "held"
+	ReferralRejected  = "rejected"
+)
+
+// Условия подтверждения реферала, включаемые в конфигурации
+const (
+	ReferralCondLanguage   = "language"    // у приглашенного есть язык: выбран или определен
+	ReferralCondActiveDays = "active_days" // приглашенный был активен заданное число дней
+	ReferralCondChannel    = "channel"     // приглашенный подписан на обязательные чаты
+)
+
+// Как приглашенный получил язык (referrals.language_chosen). Условие language выполняется
+// в обоих случаях, а взаимодействием для антифрода считается только ручной выбор
+const (
+	LanguageNotChosen = 0
+	LanguagePicked    = 1 // выбран в меню или через /language
+	LanguageDetected  = 2 // определен по клиенту Telegram
+)
+
+// ReferralConditions - условия, которые можно указать в REFERRAL_CONFIRM
+var ReferralConditions = []string{ReferralCondLanguage, ReferralCondActiveDays, ReferralCondChannel}
+
+// ReferralSignals - признаки реферала, по которым антифрод оценивает его подозрительность
+type ReferralSignals struct {
+	ReferrerID int64
+	ReferredID int64
+
+	// Сколько рефералов привел реферер за час до этого реферала
+	RecentReferrals int
+	// Сколько других рефералов реферера имеют близкие Telegram ID
+	NearbyIDs int
+	// Взаимодействовал ли приглашенный с ботом после /start
+	Interacted bool
+	// Сколько рефереров подряд вверх по цепочке зарегистрировались недавно
+	FreshChainDepth int
+	// В цепочке рефереров найден цикл
+	Cycle bool
+}
+
+// FraudVerdict - результат оценки реферала антифродом
+type FraudVerdict struct {
+	Score      int
+	Reasons    []string
+	Suspicious bool
+}
+
+// HeldReferral - реферал, награда за который задержана до проверки
+type HeldReferral struct {
+	ReferrerID int64
+	ReferredID int64
+	Score      int
+	Reasons    []string
+}
+
+// FlaggedReferrer - строка ежедневного отчета антифрода
+type FlaggedReferrer struct {
+	ReferrerID int64
+	Flagged    int
+	MaxScore   int
+}
+
+// ReferralEntry - приглашенный пользователь в списке рефералов
+type ReferralEntry struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+	Status   string    `json:"status"`
+	// Сколько пригласивший заработал на этом реферале
+	Earned float64 `json:"earned"`
+}
+
+// ReferralNode - пользователь в дереве приглашений вместе с его рефералами
+type ReferralNode struct {
+	ReferralEntry
+	Children []*ReferralNode `json:"children"`
+}
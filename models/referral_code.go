@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// ReferralCode - реферальный код пользователя. Основной код имеет пустое имя,
+// именованные коды пользователь создает сам, чтобы отслеживать источники приглашений
+type ReferralCode struct {
+	Code      string    `json:"code"`
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Invites   int       `json:"invites"`
+	CreatedAt time.Time `json:"created_at"`
+}
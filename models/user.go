@@ -3,23 +3,70 @@ package models
 import "time"
 
 type User struct {
-	UserID     int64     `json:"user_id"`
-	Balance    float64   `json:"balance"`
-	ReferredBy *int64    `json:"referred_by"`
-	JoinDate   time.Time `json:"join_date"`
-	Language   string    `json:"language"`
-	Referrals  []int64   `json:"referrals"`
+	UserID       int64      `json:"user_id"`
+	Username     string     `json:"username"`
+	Balance      float64    `json:"balance"`
+	ReferredBy   *int64     `json:"referred_by"`
+	JoinDate     time.Time  `json:"join_date"`
+	Language     string     `json:"language"`
+	IsActive     bool       `json:"is_active"`
+	BlockedAt    *time.Time `json:"blocked_at"`
+	Banned       bool       `json:"banned"`
+	Frozen       bool       `json:"frozen"`
+	NoRewards    bool       `json:"no_rewards"`
+	LastActivity *time.Time `json:"last_activity"`
+	Referrals    []int64    `json:"referrals"`
+
+	// Пройдена ли капча при регистрации
+	CaptchaPassed bool `json:"captcha_passed"`
+	// Рекламная кампания, по ссылке которой пришел пользователь
+	Campaign string `json:"campaign,omitempty"`
+
+	// Сколько из Referrals еще не подтверждены или задержаны антифродом
+	PendingReferrals int `json:"pending_referrals"`
+	// Сколько из Referrals отклонены администратором
+	RejectedReferrals int `json:"rejected_referrals"`
+}
+
+// Ограничения, которые администратор может наложить на пользователя
+const (
+	RestrictionBanned    = "banned"
+	RestrictionFrozen    = "frozen"
+	RestrictionNoRewards = "no_rewards"
+)
+
+// CanEarnRewards сообщает, можно ли начислять пользователю реферальные награды
+func (u *User) CanEarnRewards() bool {
+	return !u.Banned && !u.NoRewards
+}
+
+// ConfirmedReferrals возвращает количество подтвержденных рефералов
+func (u *User) ConfirmedReferrals() int {
+	return len(u.Referrals) - u.PendingReferrals - u.RejectedReferrals
 }
 
 type UserSession struct {
 	State                 string
 	AwaitingWalletAmount  float64
 	AwaitingBalanceUserID int64
+	AwaitingMessageUserID int64
+	BalanceOp             string
+	BalanceValue          float64
+	BalanceReason         string
+	GiftRecipientID       int64
+	GiftAmount            float64
+	GiftNote              string
+	// Редактируемый перевод: язык, ключ и новый текст до подтверждения
+	TextLang  string
+	TextKey   string
+	TextValue string
 }
 
 type Stats struct {
-	Total int `json:"total"`
-	Day   int `json:"day"`
-	Week  int `json:"week"`
-	Month int `json:"month"`
+	Total   int `json:"total"`
+	Active  int `json:"active"`
+	Blocked int `json:"blocked"`
+	Day     int `json:"day"`
+	Week    int `json:"week"`
+	Month   int `json:"month"`
 }
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Статусы подарочных ваучеров
+const (
+	VoucherActive  = "active"
+	VoucherClaimed = "claimed"
+	VoucherExpired = "expired"
+)
+
+type Voucher struct {
+	Code        string     `json:"code"`
+	SenderID    int64      `json:"sender_id"`
+	Amount      float64    `json:"amount"`
+	RecipientID *int64     `json:"recipient_id"`
+	Status      string     `json:"status"`
+	ClaimedBy   *int64     `json:"claimed_by"`
+	ClaimedAt   *time.Time `json:"claimed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Условия разблокировки приветственного бонуса, включаемые в конфигурации
+const (
+	BonusUnlockOnboarding = "onboarding" // пользователь выбрал язык и прошел капчу
+	BonusUnlockInvite     = "invite"     // у пользователя есть подтвержденный реферал
+)
+
+// BonusSourceReferral - источник бонуса за переход по реферальной ссылке. Такой бонус
+// разблокируется, только пока реферал не задержан и не отклонен антифродом
+const BonusSourceReferral = "referral"
+
+// BonusUnlockConditions - условия, которые можно указать в WELCOME_BONUS_UNLOCK
+var BonusUnlockConditions = []string{BonusUnlockOnboarding, BonusUnlockInvite}
+
+// WelcomeBonus - приветственный бонус приглашенного пользователя. Пока бонус
+// заблокирован, он не входит в баланс и попадает в журнал только при разблокировке
+type WelcomeBonus struct {
+	UserID     int64      `json:"user_id"`
+	Amount     float64    `json:"amount"`
+	Source     string     `json:"source"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UnlockedAt *time.Time `json:"unlocked_at"`
+}
+
+// Locked сообщает, ждет ли бонус выполнения условий
+func (b *WelcomeBonus) Locked() bool {
+	return b.UnlockedAt == nil
+}
@@ -0,0 +1,133 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math/rand"
+)
+
+// TextOptions задает параметры отрисовки строки растровым шрифтом
+type TextOptions struct {
+	Scale      int         // размер пикселя глифа
+	Padding    int         // отступ от краев в пикселях изображения
+	Foreground color.Color // цвет символов
+	Background color.Color // цвет фона
+	Noise      bool        // добавить случайные линии и сдвиги символов (для капчи)
+}
+
+// DefaultTextOptions - крупный темный текст на светлом фоне
+var DefaultTextOptions = TextOptions{
+	Scale:      6,
+	Padding:    12,
+	Foreground: color.RGBA{0x22, 0x22, 0x33, 0xFF},
+	Background: color.RGBA{0xF4, 0xF1, 0xE8, 0xFF},
+}
+
+// Text рисует строку растровым шрифтом. Неподдерживаемые символы пропускаются
+func Text(text string, opts TextOptions) *image.RGBA {
+	var runes []rune
+	for _, r := range text {
+		if Supported(r) {
+			runes = append(runes, r)
+		}
+	}
+
+	advance := (glyphWidth + 1) * opts.Scale
+	width := len(runes)*advance - opts.Scale + 2*opts.Padding
+	height := glyphHeight*opts.Scale + 2*opts.Padding
+	if width < 2*opts.Padding {
+		width = 2 * opts.Padding
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{opts.Background}, image.Point{}, draw.Src)
+
+	for i, r := range runes {
+		x := opts.Padding + i*advance
+		y := opts.Padding
+		if opts.Noise {
+			// Сдвигаем символы по вертикали, чтобы затруднить распознавание
+			y += rand.Intn(opts.Padding+1) - opts.Padding/2
+		}
+		drawGlyph(img, glyphs[r], x, y, opts.Scale, opts.Foreground)
+	}
+
+	if opts.Noise {
+		addNoise(img, opts.Foreground)
+	}
+
+	return img
+}
+
+func drawGlyph(img *image.RGBA, glyph [glyphHeight]uint8, x, y, scale int, c color.Color) {
+	for row := 0; row < glyphHeight; row++ {
+		for col := 0; col < glyphWidth; col++ {
+			if glyph[row]&(1<<(glyphWidth-1-col)) == 0 {
+				continue
+			}
+			rect := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale)
+			draw.Draw(img, rect, &image.Uniform{c}, image.Point{}, draw.Src)
+		}
+	}
+}
+
+// addNoise рисует несколько случайных линий и рассыпает точки поверх текста
+func addNoise(img *image.RGBA, c color.Color) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	for i := 0; i < 4; i++ {
+		Line(img, rand.Intn(w), rand.Intn(h), rand.Intn(w), rand.Intn(h), c)
+	}
+	for i := 0; i < w*h/40; i++ {
+		img.Set(rand.Intn(w), rand.Intn(h), c)
+	}
+}
+
+// Line рисует отрезок алгоритмом Брезенхэма
+func Line(img draw.Image, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	err := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// PNG кодирует изображение в PNG
+func PNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
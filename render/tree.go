@@ -0,0 +1,177 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// TreeNode - узел дерева: несколько строк текста в рамке заданного цвета
+type TreeNode struct {
+	Lines    []string
+	Color    color.Color
+	Children []*TreeNode
+}
+
+// TreeOptions задает параметры отрисовки дерева
+type TreeOptions struct {
+	Scale       int // размер пикселя глифа
+	Padding     int // отступ от краев изображения
+	NodePadding int // отступ текста от рамки узла
+	HGap        int // расстояние между соседними поддеревьями
+	VGap        int // расстояние между уровнями
+	Foreground  color.Color
+	Background  color.Color
+	NodeFill    color.Color
+	Edge        color.Color
+}
+
+// DefaultTreeOptions - мелкий текст, чтобы на изображение помещались большие деревья
+var DefaultTreeOptions = TreeOptions{
+	Scale:       2,
+	Padding:     16,
+	NodePadding: 6,
+	HGap:        10,
+	VGap:        24,
+	Foreground:  color.RGBA{0x22, 0x22, 0x33, 0xFF},
+	Background:  color.RGBA{0xF4, 0xF1, 0xE8, 0xFF},
+	NodeFill:    color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	Edge:        color.RGBA{0x99, 0x99, 0xA0, 0xFF},
+}
+
+// treeLayout - размеры и положение узла на изображении
+type treeLayout struct {
+	node     *TreeNode
+	lines    []string
+	width    int // ширина рамки
+	subtree  int // ширина всего поддерева
+	x, y     int // левый верхний угол рамки
+	children []*treeLayout
+}
+
+// Tree рисует дерево сверху вниз: родитель по центру над своими потомками.
+// Строки узлов приводятся к символам шрифта через Fit
+func Tree(root *TreeNode, opts TreeOptions) *image.RGBA {
+	lineHeight := (glyphHeight + 2) * opts.Scale
+	maxLines := 1
+
+	var measure func(node *TreeNode) *treeLayout
+	measure = func(node *TreeNode) *treeLayout {
+		layout := &treeLayout{node: node}
+		for _, line := range node.Lines {
+			line = Fit(line)
+			layout.lines = append(layout.lines, line)
+			if w := textWidth(line, opts.Scale); w > layout.width {
+				layout.width = w
+			}
+		}
+		layout.width += 2 * opts.NodePadding
+		if len(layout.lines) > maxLines {
+			maxLines = len(layout.lines)
+		}
+
+		children := 0
+		for i, child := range node.Children {
+			childLayout := measure(child)
+			layout.children = append(layout.children, childLayout)
+			if i > 0 {
+				children += opts.HGap
+			}
+			children += childLayout.subtree
+		}
+
+		layout.subtree = layout.width
+		if children > layout.subtree {
+			layout.subtree = children
+		}
+		return layout
+	}
+
+	rootLayout := measure(root)
+	nodeHeight := maxLines*lineHeight - 2*opts.Scale + 2*opts.NodePadding
+	levelHeight := nodeHeight + opts.VGap
+
+	depth := 0
+	var place func(layout *treeLayout, left, level int)
+	place = func(layout *treeLayout, left, level int) {
+		if level+1 > depth {
+			depth = level + 1
+		}
+		layout.x = left + (layout.subtree-layout.width)/2
+		layout.y = opts.Padding + level*levelHeight
+
+		children := -opts.HGap
+		for _, child := range layout.children {
+			children += child.subtree + opts.HGap
+		}
+		childLeft := left + (layout.subtree-children)/2
+		for _, child := range layout.children {
+			place(child, childLeft, level+1)
+			childLeft += child.subtree + opts.HGap
+		}
+	}
+	place(rootLayout, opts.Padding, 0)
+
+	width := rootLayout.subtree + 2*opts.Padding
+	height := depth*levelHeight - opts.VGap + 2*opts.Padding
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{opts.Background}, image.Point{}, draw.Src)
+
+	thickness := opts.Scale / 2
+	if thickness < 1 {
+		thickness = 1
+	}
+
+	var paint func(layout *treeLayout)
+	paint = func(layout *treeLayout) {
+		// Связи рисуем уголком: вниз от родителя, вбок и вниз к потомку
+		parentX := layout.x + layout.width/2
+		bottom := layout.y + nodeHeight
+		middle := bottom + opts.VGap/2
+		for _, child := range layout.children {
+			childX := child.x + child.width/2
+			fillRect(img, parentX, bottom, parentX+thickness, middle+thickness, opts.Edge)
+			left, right := parentX, childX
+			if left > right {
+				left, right = right, left
+			}
+			fillRect(img, left, middle, right+thickness, middle+thickness, opts.Edge)
+			fillRect(img, childX, middle, childX+thickness, child.y, opts.Edge)
+			paint(child)
+		}
+
+		border := layout.node.Color
+		if border == nil {
+			border = opts.Foreground
+		}
+		fillRect(img, layout.x, layout.y, layout.x+layout.width, layout.y+nodeHeight, border)
+		fillRect(img, layout.x+opts.Scale, layout.y+opts.Scale,
+			layout.x+layout.width-opts.Scale, layout.y+nodeHeight-opts.Scale, opts.NodeFill)
+
+		for i, line := range layout.lines {
+			x := layout.x + (layout.width-textWidth(line, opts.Scale))/2
+			y := layout.y + opts.NodePadding + i*lineHeight
+			for _, r := range line {
+				drawGlyph(img, glyphs[r], x, y, opts.Scale, opts.Foreground)
+				x += (glyphWidth + 1) * opts.Scale
+			}
+		}
+	}
+	paint(rootLayout)
+
+	return img
+}
+
+// textWidth возвращает ширину строки из поддерживаемых шрифтом символов
+func textWidth(text string, scale int) int {
+	n := len([]rune(text))
+	if n == 0 {
+		return 0
+	}
+	return n*(glyphWidth+1)*scale - scale
+}
+
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	draw.Draw(img, image.Rect(x0, y0, x1, y1), &image.Uniform{c}, image.Point{}, draw.Src)
+}
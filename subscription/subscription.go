@@ -0,0 +1,172 @@
+package subscription
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Client - часть Telegram клиента, нужная для проверки подписки.
+// *tgbotapi.BotAPI реализует его, в проверках можно подставить заглушку
+type Client interface {
+	GetChatMember(config tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error)
+}
+
+// Chat - канал или группа, на которые пользователь должен быть подписан
+type Chat struct {
+	ID       int64  // числовой ID приватного чата
+	Username string // @username публичного чата без @
+	Link     string // ссылка для кнопки "Подписаться"
+}
+
+// ParseChat разбирает запись вида "@channel" или "-1001234567890|https://t.me/+invite"
+func ParseChat(value string) (Chat, error) {
+	var chat Chat
+
+	ref, link, _ := strings.Cut(strings.TrimSpace(value), "|")
+	ref = strings.TrimSpace(ref)
+	chat.Link = strings.TrimSpace(link)
+
+	switch {
+	case strings.HasPrefix(ref, "@") && len(ref) > 1:
+		chat.Username = strings.TrimPrefix(ref, "@")
+		if chat.Link == "" {
+			chat.Link = "https://t.me/" + chat.Username
+		}
+	default:
+		id, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil {
+			return chat, fmt.Errorf("invalid chat %q: expected @username or numeric ID", ref)
+		}
+		if chat.Link == "" {
+			return chat, fmt.Errorf("chat %d needs an invite link: use ID|link", id)
+		}
+		chat.ID = id
+	}
+
+	return chat, nil
+}
+
+// UnmarshalYAML позволяет задавать чаты строками ParseChat в файле конфигурации.
+// Ошибка возвращается как *yaml.TypeError: декодер продолжает разбор файла и
+// сообщает о ней вместе с остальными проблемами
+func (c *Chat) UnmarshalYAML(value *yaml.Node) error {
+	var text string
+	if err := value.Decode(&text); err != nil {
+		return err
+	}
+	chat, err := ParseChat(text)
+	if err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: %v", value.Line, err)}}
+	}
+	*c = chat
+	return nil
+}
+
+type cacheEntry struct {
+	missing   []Chat
+	checkedAt time.Time
+}
+
+// Checker проверяет подписку на обязательные чаты и кэширует результат на ttl
+type Checker struct {
+	client Client
+	chats  []Chat
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[int64]cacheEntry
+}
+
+func NewChecker(client Client, chats []Chat, ttl time.Duration) *Checker {
+	return &Checker{
+		client: client,
+		chats:  chats,
+		ttl:    ttl,
+		cache:  make(map[int64]cacheEntry),
+	}
+}
+
+// Enabled сообщает, настроены ли обязательные чаты
+func (c *Checker) Enabled() bool {
+	return len(c.chats) > 0
+}
+
+// Chats возвращает все обязательные чаты
+func (c *Checker) Chats() []Chat {
+	return c.chats
+}
+
+// Missing возвращает чаты, на которые пользователь не подписан
+func (c *Checker) Missing(userID int64) ([]Chat, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	entry, ok := c.cache[userID]
+	c.mu.Unlock()
+	if ok && time.Since(entry.checkedAt) < c.ttl {
+		return entry.missing, nil
+	}
+
+	var missing []Chat
+	for _, chat := range c.chats {
+		member, err := c.client.GetChatMember(tgbotapi.GetChatMemberConfig{
+			ChatConfigWithUser: tgbotapi.ChatConfigWithUser{
+				ChatID:             chat.ID,
+				SuperGroupUsername: chatUsername(chat),
+				UserID:             userID,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("get chat member %s: %w", chat, err)
+		}
+		if !isMember(member) {
+			missing = append(missing, chat)
+		}
+	}
+
+	c.mu.Lock()
+	c.cache[userID] = cacheEntry{missing: missing, checkedAt: time.Now()}
+	c.mu.Unlock()
+
+	return missing, nil
+}
+
+// Invalidate сбрасывает кэш пользователя, например после нажатия "Я подписался"
+func (c *Checker) Invalidate(userID int64) {
+	c.mu.Lock()
+	delete(c.cache, userID)
+	c.mu.Unlock()
+}
+
+func (chat Chat) String() string {
+	if chat.Username != "" {
+		return "@" + chat.Username
+	}
+	return strconv.FormatInt(chat.ID, 10)
+}
+
+func chatUsername(chat Chat) string {
+	if chat.Username == "" {
+		return ""
+	}
+	return "@" + chat.Username
+}
+
+func isMember(member tgbotapi.ChatMember) bool {
+	switch member.Status {
+	case "creator", "administrator", "member":
+		return true
+	case "restricted":
+		return member.IsMember
+	default:
+		return false
+	}
+}
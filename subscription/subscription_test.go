@@ -0,0 +1,196 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeClient отвечает статусом участника по чату и считает запросы
+type fakeClient struct {
+	statuses map[string]string // чат (ParseChat-строка) → статус участника
+	err      error
+	calls    int
+}
+
+func (f *fakeClient) GetChatMember(config tgbotapi.GetChatMemberConfig) (tgbotapi.ChatMember, error) {
+	f.calls++
+	if f.err != nil {
+		return tgbotapi.ChatMember{}, f.err
+	}
+
+	chat := config.SuperGroupUsername
+	if chat == "" {
+		chat = Chat{ID: config.ChatID}.String()
+	}
+	return tgbotapi.ChatMember{Status: f.statuses[chat]}, nil
+}
+
+func mustParseChats(t *testing.T, values ...string) []Chat {
+	t.Helper()
+	chats := make([]Chat, 0, len(values))
+	for _, value := range values {
+		chat, err := ParseChat(value)
+		if err != nil {
+			t.Fatalf("ParseChat(%q): %v", value, err)
+		}
+		chats = append(chats, chat)
+	}
+	return chats
+}
+
+func TestMissing(t *testing.T) {
+	client := &fakeClient{statuses: map[string]string{
+		"@news":          "member",
+		"-1001234567890": "left",
+	}}
+	chats := mustParseChats(t, "@news", "-1001234567890|https://t.me/+invite", "@chat")
+	checker := NewChecker(client, chats, time.Hour)
+
+	missing, err := checker.Missing(42)
+	if err != nil {
+		t.Fatalf("Missing: %v", err)
+	}
+	if len(missing) != 2 || missing[0].ID != -1001234567890 || missing[1].Username != "chat" {
+		t.Fatalf("Missing = %v, want [-1001234567890 @chat]", missing)
+	}
+}
+
+func TestMissingStatuses(t *testing.T) {
+	tests := []struct {
+		status   string
+		isMember bool
+		missing  bool
+	}{
+		{"creator", false, false},
+		{"administrator", false, false},
+		{"member", false, false},
+		{"restricted", true, false},
+		{"restricted", false, true},
+		{"left", false, true},
+		{"kicked", false, true},
+	}
+
+	for _, tt := range tests {
+		member := tgbotapi.ChatMember{Status: tt.status, IsMember: tt.isMember}
+		if got := !isMember(member); got != tt.missing {
+			t.Errorf("status %q (is_member %v): missing = %v, want %v", tt.status, tt.isMember, got, tt.missing)
+		}
+	}
+}
+
+func TestMissingError(t *testing.T) {
+	client := &fakeClient{err: errors.New("bot is not a member of the channel")}
+	checker := NewChecker(client, mustParseChats(t, "@news"), time.Hour)
+
+	missing, err := checker.Missing(42)
+	if err == nil {
+		t.Fatalf("Missing = %v, want error", missing)
+	}
+
+	// Ошибка не кэшируется: следующая проверка снова обращается к Telegram
+	client.err = nil
+	client.statuses = map[string]string{"@news": "member"}
+	if missing, err := checker.Missing(42); err != nil || len(missing) != 0 {
+		t.Fatalf("Missing after error = %v, %v, want none", missing, err)
+	}
+}
+
+func TestMissingDisabled(t *testing.T) {
+	client := &fakeClient{}
+	checker := NewChecker(client, nil, time.Hour)
+
+	if checker.Enabled() {
+		t.Fatal("Enabled() = true without chats")
+	}
+	if missing, err := checker.Missing(42); err != nil || missing != nil || client.calls != 0 {
+		t.Fatalf("Missing = %v, %v with %d calls, want nothing", missing, err, client.calls)
+	}
+}
+
+func TestMissingCache(t *testing.T) {
+	client := &fakeClient{statuses: map[string]string{"@news": "left"}}
+	checker := NewChecker(client, mustParseChats(t, "@news"), time.Hour)
+
+	checker.Missing(42)
+	client.statuses["@news"] = "member"
+
+	// В пределах ttl используется сохраненный результат
+	missing, _ := checker.Missing(42)
+	if len(missing) != 1 || client.calls != 1 {
+		t.Fatalf("cached Missing = %v with %d calls, want 1 chat and 1 call", missing, client.calls)
+	}
+
+	// Кэш хранится отдельно для каждого пользователя
+	if missing, _ := checker.Missing(7); len(missing) != 0 || client.calls != 2 {
+		t.Fatalf("Missing(7) = %v with %d calls, want none and 2 calls", missing, client.calls)
+	}
+}
+
+func TestMissingCacheExpired(t *testing.T) {
+	client := &fakeClient{statuses: map[string]string{"@news": "left"}}
+	checker := NewChecker(client, mustParseChats(t, "@news"), 0)
+
+	checker.Missing(42)
+	client.statuses["@news"] = "member"
+
+	if missing, _ := checker.Missing(42); len(missing) != 0 || client.calls != 2 {
+		t.Fatalf("Missing with expired cache = %v with %d calls, want none and 2 calls", missing, client.calls)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	client := &fakeClient{statuses: map[string]string{"@news": "left"}}
+	checker := NewChecker(client, mustParseChats(t, "@news"), time.Hour)
+
+	checker.Missing(42)
+	checker.Missing(7)
+	client.statuses["@news"] = "member"
+	checker.Invalidate(42)
+
+	if missing, _ := checker.Missing(42); len(missing) != 0 {
+		t.Fatalf("Missing after Invalidate = %v, want none", missing)
+	}
+	// Кэш других пользователей не сбрасывается
+	if missing, _ := checker.Missing(7); len(missing) != 1 {
+		t.Fatalf("Missing(7) = %v, want cached chat", missing)
+	}
+	if client.calls != 3 {
+		t.Fatalf("calls = %d, want 3", client.calls)
+	}
+}
+
+func TestParseChat(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Chat
+		wantErr bool
+	}{
+		{value: "@news", want: Chat{Username: "news", Link: "https://t.me/news"}},
+		{value: " @news | https://t.me/+join ", want: Chat{Username: "news", Link: "https://t.me/+join"}},
+		{value: "-1001234567890|https://t.me/+invite", want: Chat{ID: -1001234567890, Link: "https://t.me/+invite"}},
+		{value: "-1001234567890", wantErr: true},
+		{value: "@", wantErr: true},
+		{value: "news", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseChat(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseChat(%q) = %+v, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseChat(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseChat(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}